@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	farcaster "github.com/ertan/go-farcaster/pkg"
+	"github.com/ertan/go-farcaster/pkg/notifications"
+	"github.com/ertan/go-farcaster/pkg/relay"
+	"github.com/spf13/viper"
+)
+
+type config struct {
+	Endpoints         []relay.Endpoint `json:"endpoints"`
+	Fids              []uint64         `json:"fids"`
+	Interval          string           `json:"interval"`
+	SkipNotifications bool             `json:"skipNotifications"`
+	MaxAttempts       int              `json:"maxAttempts"`
+	DataDir           string           `json:"dataDir"`
+}
+
+func main() {
+	configPath := flag.String("config", "relay.json", "relay configuration file")
+	replay := flag.Bool("replay", false, "replay dead lettered deliveries and exit")
+	flag.Parse()
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Fatalf("%s: %s", *configPath, err)
+	}
+	interval := 30 * time.Second
+	if cfg.Interval != "" {
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil {
+			log.Fatalf("interval: %s", err)
+		}
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "relay-data"
+	}
+	deadLetters, err := relay.NewDirDeadLetters(cfg.DataDir + "/dead-letters")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	viper.SetConfigFile(".env")
+	viper.ReadInConfig()
+	client := farcaster.NewFarcasterClient(viper.GetString("FARCASTER_API_URL"), viper.GetString("FARCASTER_MNEMONIC"), "")
+
+	r, err := relay.NewRelay(client.Notifications, client.Casts, &relay.Options{
+		Endpoints:         cfg.Endpoints,
+		Fids:              cfg.Fids,
+		Interval:          interval,
+		SkipNotifications: cfg.SkipNotifications,
+		WatchOptions: &notifications.WatchOptions{
+			Checkpoint:  notifications.NewFileCheckpoint(cfg.DataDir + "/notifications.json"),
+			SkipBacklog: true,
+		},
+		MaxAttempts: cfg.MaxAttempts,
+		DeadLetters: deadLetters,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *replay {
+		failed, err := r.Replay(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d deliveries still failing\n", failed)
+		return
+	}
+	log.Printf("Relaying to %d endpoints every %s", len(cfg.Endpoints), interval)
+	if err := r.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
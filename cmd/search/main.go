@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	farcaster "github.com/ertan/go-farcaster/pkg"
+	"github.com/ertan/go-farcaster/pkg/search"
+	"github.com/spf13/viper"
+)
+
+type fidList []uint64
+
+func (f *fidList) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *fidList) Set(value string) error {
+	var fid uint64
+	if _, err := fmt.Sscanf(value, "%d", &fid); err != nil {
+		return err
+	}
+	*f = append(*f, fid)
+	return nil
+}
+
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func main() {
+	indexPath := flag.String("index", "casts.idx", "index file, created if missing")
+	limit := flag.Int("limit", 20, "maximum number of results")
+	var files fileList
+	var fids fidList
+	flag.Var(&files, "add", "JSONL file of casts or archive records to index (repeatable)")
+	flag.Var(&fids, "fid", "fetch and index every cast of this fid from the API (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [query]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), `Query syntax: words, "exact phrases", -excluded, from:username, -from:username, fid:3, after:2023-01-02, before:2023-02-01`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	index, err := loadIndex(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	changed := false
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		count, err := index.AddJSONL(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", path, err)
+		}
+		log.Printf("Indexed %d casts from %s", count, path)
+		changed = true
+	}
+	if len(fids) > 0 {
+		viper.SetConfigFile(".env")
+		viper.ReadInConfig()
+		client := farcaster.NewFarcasterClient(viper.GetString("FARCASTER_API_URL"), viper.GetString("FARCASTER_MNEMONIC"), "")
+		for _, fid := range fids {
+			count, err := index.AddCastsByFid(client.Casts, fid)
+			if err != nil {
+				log.Fatalf("fid %d: %s", fid, err)
+			}
+			log.Printf("Indexed %d casts of fid %d", count, fid)
+		}
+		changed = true
+	}
+	if changed {
+		if err := saveIndex(*indexPath, index); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	query := strings.Join(flag.Args(), " ")
+	if query == "" {
+		if !changed {
+			flag.Usage()
+		}
+		return
+	}
+	results, err := index.Search(search.Query{Text: query, Limit: *limit})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, result := range results {
+		author := "unknown"
+		if result.Cast.Author != nil {
+			author = "@" + result.Cast.Author.Username
+		}
+		timestamp := time.UnixMilli(int64(result.Cast.Timestamp)).Format("2006-01-02 15:04")
+		fmt.Printf("%s  %s  %s  (%.2f)\n  %s\n\n", timestamp, author, result.Cast.Hash, result.Score,
+			strings.ReplaceAll(result.Cast.Text, "\n", "\n  "))
+	}
+	fmt.Printf("%d results\n", len(results))
+}
+
+func loadIndex(path string) (*search.Index, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return search.NewIndex(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return search.Load(file)
+}
+
+func saveIndex(path string, index *search.Index) error {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := index.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
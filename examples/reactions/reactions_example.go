@@ -34,7 +34,7 @@ func main() {
 		panic(err)
 	}
 	prettyPrint(&users)
-	reactions, _, err = farcaster.Reactions.GetUserReactions(40)
+	reactions, _, err = farcaster.Reactions.GetUserReactions(40, 0, "")
 	if err != nil {
 		panic(err)
 	}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -17,8 +18,10 @@ import (
 )
 
 type AccountService struct {
-	privateKey  *ecdsa.PrivateKey
-	apiUrl      string
+	privateKey *ecdsa.PrivateKey
+	apiUrl     string
+	// mu guards the token, services send requests from several goroutines.
+	mu          sync.Mutex
 	accessToken string
 	expiresAt   int64
 	clock       func() time.Time
@@ -69,6 +72,9 @@ func (a *AccountService) GetAccessToken(expirationInSecs int) (string, error) {
 	if a.privateKey == nil {
 		return "", errors.New("private key is nil")
 	}
+	// Held while a new token is fetched so concurrent requests share it.
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
 	timestamp := a.now().UnixMilli()
 	expiration := timestamp + int64(expirationInSecs*1000)
@@ -3,6 +3,8 @@ package account
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -33,3 +35,35 @@ func TestGetAccessToken(t *testing.T) {
 		t.Errorf("Expected 'farcaster access token', got %s", value)
 	}
 }
+
+func TestSendRequestSharesToken(t *testing.T) {
+	var auths int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/auth" {
+			atomic.AddInt32(&auths, 1)
+			w.Write([]byte(`{"result":{"token": {"secret": "farcaster access token"}}}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer farcaster access token" {
+			t.Errorf("Expected the access token, got %s", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	account := NewAccountService(server.URL, "spare trash wide forest stand solution donate wonder mixed crisp busy silent")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := account.SendRequest("GET", "/v2/me", nil, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&auths); n != 1 {
+		t.Errorf("Expected concurrent requests to share one token, got %d auth requests", n)
+	}
+}
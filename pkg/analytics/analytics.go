@@ -0,0 +1,345 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+type Options struct {
+	// PageSize is passed as limit to the paginated endpoints.
+	PageSize int
+	// Top is the number of top casts and engagers in a report. Defaults to 10.
+	Top int
+	// Location is used for the time of day histogram. Defaults to UTC.
+	Location *time.Location
+	// SkipThreads skips fetching the threads of casts with replies. Reply
+	// depth and repliers are left out of the report then.
+	SkipThreads bool
+}
+
+// CastStats is the engagement of a single cast.
+type CastStats struct {
+	Hash      string    `json:"hash"`
+	Text      string    `json:"text"`
+	Time      time.Time `json:"time"`
+	Replies   int       `json:"replies"`
+	Reactions int       `json:"reactions"`
+	Recasts   int       `json:"recasts"`
+	Watches   int       `json:"watches"`
+	// Engagement is the sum of replies, reactions and recasts.
+	Engagement int `json:"engagement"`
+	// EngagementRate is Engagement divided by the author's follower count.
+	EngagementRate float64 `json:"engagementRate"`
+	// ReplyDepth is the depth of the deepest reply below the cast.
+	ReplyDepth int `json:"replyDepth"`
+}
+
+// Engager is a user who interacted with the casts of a report.
+type Engager struct {
+	Fid       users.Fid `json:"fid"`
+	Username  string    `json:"username"`
+	Reactions int       `json:"reactions"`
+	Recasts   int       `json:"recasts"`
+	Replies   int       `json:"replies"`
+	Total     int       `json:"total"`
+}
+
+// Hour is a bucket of the time of day histogram.
+type Hour struct {
+	Hour       int `json:"hour"`
+	Casts      int `json:"casts"`
+	Engagement int `json:"engagement"`
+}
+
+type Report struct {
+	Fid       users.Fid `json:"fid"`
+	Username  string    `json:"username"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	Followers int       `json:"followers"`
+	Casts     int       `json:"casts"`
+	Replies   int       `json:"replies"`
+	Reactions int       `json:"reactions"`
+	Recasts   int       `json:"recasts"`
+	Watches   int       `json:"watches"`
+	// EngagementRate is the average engagement per cast divided by the
+	// follower count.
+	EngagementRate    float64     `json:"engagementRate"`
+	AverageReplyDepth float64     `json:"averageReplyDepth"`
+	MaxReplyDepth     int         `json:"maxReplyDepth"`
+	TopCasts          []CastStats `json:"topCasts"`
+	TopEngagers       []Engager   `json:"topEngagers"`
+	Hours             [24]Hour    `json:"hours"`
+	CastStats         []CastStats `json:"castStats"`
+}
+
+// Analyzer computes engagement reports from the casts of a fid and the
+// reactions, recasters and replies of each cast.
+type Analyzer struct {
+	casts     *casts.CastService
+	reactions *reactions.ReactionService
+	options   Options
+}
+
+func NewAnalyzer(castService *casts.CastService, reactionService *reactions.ReactionService, options *Options) *Analyzer {
+	a := &Analyzer{
+		casts:     castService,
+		reactions: reactionService,
+	}
+	if options != nil {
+		a.options = *options
+	}
+	if a.options.Top <= 0 {
+		a.options.Top = 10
+	}
+	if a.options.Location == nil {
+		a.options.Location = time.UTC
+	}
+	return a
+}
+
+// Analyze reports on the casts fid published in [since, until). A zero until
+// means now. Recasts of other users' casts are left out.
+func (a *Analyzer) Analyze(fid users.Fid, since, until time.Time) (*Report, error) {
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if !since.Before(until) {
+		return nil, errors.New("since must be before until")
+	}
+	report := &Report{
+		Fid:   fid,
+		Since: since,
+		Until: until,
+	}
+	for i := range report.Hours {
+		report.Hours[i].Hour = i
+	}
+	castList, err := a.castsInWindow(fid, since, until)
+	if err != nil {
+		return nil, err
+	}
+	engagers := make(map[users.Fid]*Engager)
+	engager := func(user *users.User) *Engager {
+		if user == nil || user.Fid == fid {
+			return nil
+		}
+		e, ok := engagers[user.Fid]
+		if !ok {
+			e = &Engager{Fid: user.Fid, Username: user.Username}
+			engagers[user.Fid] = e
+		}
+		return e
+	}
+	threads := make(map[string]*casts.Thread)
+	// Casts of fid in the same thread share replies, each reply is credited
+	// to its author once.
+	replies := make(map[string]bool)
+	depths := 0
+	for i := range castList {
+		cast := &castList[i]
+		if cast.Author != nil {
+			report.Username = cast.Author.Username
+			report.Followers = cast.Author.FollowerCount
+		}
+		stats := newCastStats(cast)
+
+		likes, err := a.allReactions(cast.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("fetching reactions of %s: %w", cast.Hash, err)
+		}
+		for _, like := range likes {
+			if e := engager(like.Reactor); e != nil {
+				e.Reactions++
+			}
+		}
+		recasters, err := a.allRecasters(cast.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("fetching recasters of %s: %w", cast.Hash, err)
+		}
+		for j := range recasters {
+			if e := engager(&recasters[j]); e != nil {
+				e.Recasts++
+			}
+		}
+		if stats.Replies > 0 && !a.options.SkipThreads {
+			node, err := a.threadNode(threads, cast)
+			if err != nil {
+				return nil, fmt.Errorf("fetching thread of %s: %w", cast.Hash, err)
+			}
+			if node != nil {
+				node.Walk(func(reply *casts.ThreadNode) bool {
+					if reply == node {
+						return true
+					}
+					if reply.Depth-node.Depth > stats.ReplyDepth {
+						stats.ReplyDepth = reply.Depth - node.Depth
+					}
+					if replies[reply.Cast.Hash] {
+						return true
+					}
+					replies[reply.Cast.Hash] = true
+					if e := engager(reply.Cast.Author); e != nil {
+						e.Replies++
+					}
+					return true
+				})
+				depths++
+			}
+		}
+		report.CastStats = append(report.CastStats, stats)
+	}
+
+	engagement := 0
+	for i := range report.CastStats {
+		stats := &report.CastStats[i]
+		if report.Followers > 0 {
+			stats.EngagementRate = float64(stats.Engagement) / float64(report.Followers)
+		}
+		report.Casts++
+		report.Replies += stats.Replies
+		report.Reactions += stats.Reactions
+		report.Recasts += stats.Recasts
+		report.Watches += stats.Watches
+		engagement += stats.Engagement
+		if stats.ReplyDepth > report.MaxReplyDepth {
+			report.MaxReplyDepth = stats.ReplyDepth
+		}
+		report.AverageReplyDepth += float64(stats.ReplyDepth)
+		hour := &report.Hours[stats.Time.In(a.options.Location).Hour()]
+		hour.Casts++
+		hour.Engagement += stats.Engagement
+	}
+	if depths > 0 {
+		report.AverageReplyDepth /= float64(depths)
+	}
+	if report.Casts > 0 && report.Followers > 0 {
+		report.EngagementRate = float64(engagement) / float64(report.Casts) / float64(report.Followers)
+	}
+
+	report.TopCasts = append([]CastStats(nil), report.CastStats...)
+	sort.SliceStable(report.TopCasts, func(i, j int) bool {
+		return report.TopCasts[i].Engagement > report.TopCasts[j].Engagement
+	})
+	if len(report.TopCasts) > a.options.Top {
+		report.TopCasts = report.TopCasts[:a.options.Top]
+	}
+	for _, e := range engagers {
+		e.Total = e.Reactions + e.Recasts + e.Replies
+		report.TopEngagers = append(report.TopEngagers, *e)
+	}
+	sort.Slice(report.TopEngagers, func(i, j int) bool {
+		if report.TopEngagers[i].Total != report.TopEngagers[j].Total {
+			return report.TopEngagers[i].Total > report.TopEngagers[j].Total
+		}
+		return report.TopEngagers[i].Fid < report.TopEngagers[j].Fid
+	})
+	if len(report.TopEngagers) > a.options.Top {
+		report.TopEngagers = report.TopEngagers[:a.options.Top]
+	}
+	return report, nil
+}
+
+func newCastStats(cast *casts.Cast) CastStats {
+	stats := CastStats{
+		Hash: cast.Hash,
+		Text: cast.Text,
+		Time: time.UnixMilli(int64(cast.Timestamp)).UTC(),
+	}
+	if cast.Replies != nil {
+		stats.Replies = cast.Replies.Count
+	}
+	if cast.Reactions != nil {
+		stats.Reactions = cast.Reactions.Count
+	}
+	if cast.Recasts != nil {
+		stats.Recasts = cast.Recasts.Count
+	}
+	if cast.Watches != nil {
+		stats.Watches = cast.Watches.Count
+	}
+	stats.Engagement = stats.Replies + stats.Reactions + stats.Recasts
+	return stats
+}
+
+// castsInWindow pages through the casts of fid, newest first, until it
+// reaches casts older than since.
+func (a *Analyzer) castsInWindow(fid users.Fid, since, until time.Time) ([]casts.Cast, error) {
+	var window []casts.Cast
+	cursor := ""
+	for {
+		page, next, err := a.casts.GetCastsByFid(fid, a.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		reached := false
+		for _, cast := range page {
+			created := time.UnixMilli(int64(cast.Timestamp))
+			if created.Before(since) {
+				reached = true
+				continue
+			}
+			if cast.Recast || !created.Before(until) {
+				continue
+			}
+			window = append(window, cast)
+		}
+		if reached || next == "" || len(page) == 0 {
+			return window, nil
+		}
+		cursor = next
+	}
+}
+
+// threadNode returns the node of cast in its thread, fetching each thread
+// once.
+func (a *Analyzer) threadNode(threads map[string]*casts.Thread, cast *casts.Cast) (*casts.ThreadNode, error) {
+	thread, ok := threads[cast.ThreadHash]
+	if !ok {
+		var err error
+		thread, err = a.casts.GetThread(cast.ThreadHash)
+		if err != nil {
+			return nil, err
+		}
+		threads[cast.ThreadHash] = thread
+	}
+	return thread.Node(cast.Hash), nil
+}
+
+func (a *Analyzer) allReactions(hash string) ([]reactions.Reaction, error) {
+	var all []reactions.Reaction
+	cursor := ""
+	for {
+		page, next, err := a.reactions.GetReactionsByCastHash(hash, a.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+func (a *Analyzer) allRecasters(hash string) ([]users.User, error) {
+	var all []users.User
+	cursor := ""
+	for {
+		page, next, err := a.reactions.GetRecastersByCastHash(hash, a.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
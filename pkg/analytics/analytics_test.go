@@ -0,0 +1,136 @@
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+)
+
+func TestAnalyze(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	ms := func(hours int) int64 {
+		return day.Add(time.Duration(hours) * time.Hour).UnixMilli()
+	}
+	author := `{"fid":1,"username":"alice","followerCount":10}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/casts":
+			// The second page is older than the window and must not be fetched.
+			if r.URL.Query().Get("cursor") != "" {
+				t.Errorf("Expected paging to stop before the window")
+			}
+			fmt.Fprintf(w, `{"result":{"casts":[
+				{"hash":"0xb","threadHash":"0xb","text":"quiet","timestamp":%d,"author":%s},
+				{"hash":"0xr","threadHash":"0xr","recast":true,"timestamp":%d,"author":%s},
+				{"hash":"0xa","threadHash":"0xa","text":"hello","timestamp":%d,"author":%s,
+				 "replies":{"count":2},"reactions":{"count":2},"recasts":{"count":1}},
+				{"hash":"0xo","threadHash":"0xo","text":"old","timestamp":%d,"author":%s}
+			]},"next":{"cursor":"2"}}`, ms(15), author, ms(12), author, ms(9), author, ms(-1), author)
+		case "/v2/cast-likes":
+			if r.URL.Query().Get("castHash") == "0xa" {
+				fmt.Fprint(w, `{"result":{"likes":[{"reactor":{"fid":2,"username":"bob"}},{"reactor":{"fid":3,"username":"carol"}}]}}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":{"likes":[]}}`)
+		case "/v2/cast-recasters":
+			if r.URL.Query().Get("castHash") == "0xa" {
+				fmt.Fprint(w, `{"result":{"users":[{"fid":3,"username":"carol"}]}}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":{"users":[]}}`)
+		case "/v2/all-casts-in-thread":
+			fmt.Fprintf(w, `{"result":{"casts":[
+				{"hash":"0xa","threadHash":"0xa","timestamp":%d,"author":%s},
+				{"hash":"0x1","threadHash":"0xa","parentHash":"0xa","timestamp":%d,"author":{"fid":2,"username":"bob"}},
+				{"hash":"0x2","threadHash":"0xa","parentHash":"0x1","timestamp":%d,"author":%s}
+			]}}`, ms(9), author, ms(10), ms(11), author)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	accountService := account.NewAccountService(server.URL, "")
+	analyzer := NewAnalyzer(casts.NewCastService(accountService, nil), reactions.NewReactionService(accountService), nil)
+	report, err := analyzer.Analyze(1, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Casts != 2 || report.Followers != 10 || report.Username != "alice" {
+		t.Fatalf("Expected 2 casts by alice with 10 followers, got %+v", report)
+	}
+	if report.EngagementRate != 0.25 {
+		t.Errorf("Expected an engagement rate of 0.25, got %v", report.EngagementRate)
+	}
+	if report.TopCasts[0].Hash != "0xa" || report.MaxReplyDepth != 2 || report.AverageReplyDepth != 2 {
+		t.Errorf("Expected 0xa on top with a reply depth of 2, got %+v", report)
+	}
+	if report.Hours[9].Casts != 1 || report.Hours[9].Engagement != 5 || report.Hours[15].Casts != 1 {
+		t.Errorf("Expected casts at 9 and 15, got %+v", report.Hours)
+	}
+	engagers := report.TopEngagers
+	if len(engagers) != 2 || engagers[0].Fid != 2 || engagers[0].Replies != 1 || engagers[1].Recasts != 1 || engagers[1].Total != 2 {
+		t.Errorf("Expected bob and carol as engagers without alice, got %+v", engagers)
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != 3 {
+		t.Errorf("Expected header and 2 rows, got %d lines", lines)
+	}
+	var json bytes.Buffer
+	if err := report.WriteJSON(&json); err != nil || !strings.Contains(json.String(), `"engagementRate": 0.25`) {
+		t.Errorf("Expected the JSON report to include the engagement rate, got %v", err)
+	}
+}
+
+func TestAnalyzeCountsThreadRepliesOnce(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	ms := func(hours int) int64 {
+		return day.Add(time.Duration(hours) * time.Hour).UnixMilli()
+	}
+	author := `{"fid":1,"username":"alice","followerCount":10}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/casts":
+			// A thread of two casts by alice, bob replied to the second one.
+			fmt.Fprintf(w, `{"result":{"casts":[
+				{"hash":"0xb","threadHash":"0xa","parentHash":"0xa","timestamp":%d,"author":%s,"replies":{"count":1}},
+				{"hash":"0xa","threadHash":"0xa","timestamp":%d,"author":%s,"replies":{"count":1}}
+			]}}`, ms(2), author, ms(1), author)
+		case "/v2/cast-likes":
+			fmt.Fprint(w, `{"result":{"likes":[]}}`)
+		case "/v2/cast-recasters":
+			fmt.Fprint(w, `{"result":{"users":[]}}`)
+		case "/v2/all-casts-in-thread":
+			fmt.Fprintf(w, `{"result":{"casts":[
+				{"hash":"0xa","threadHash":"0xa","timestamp":%d,"author":%s},
+				{"hash":"0xb","threadHash":"0xa","parentHash":"0xa","timestamp":%d,"author":%s},
+				{"hash":"0xc","threadHash":"0xa","parentHash":"0xb","timestamp":%d,"author":{"fid":2,"username":"bob"}}
+			]}}`, ms(1), author, ms(2), author, ms(3))
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	accountService := account.NewAccountService(server.URL, "")
+	analyzer := NewAnalyzer(casts.NewCastService(accountService, nil), reactions.NewReactionService(accountService), nil)
+	report, err := analyzer.Analyze(1, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.TopEngagers) != 1 || report.TopEngagers[0].Replies != 1 {
+		t.Errorf("Expected bob's reply to be counted once, got %+v", report.TopEngagers)
+	}
+}
@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteJSON writes the whole report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per cast of the report.
+func (r *Report) WriteCSV(w io.Writer) error {
+	return writeCSV(w, []string{
+		"hash", "time", "text", "replies", "reactions", "recasts", "watches",
+		"engagement", "engagementRate", "replyDepth",
+	}, len(r.CastStats), func(i int) []string {
+		stats := r.CastStats[i]
+		return []string{
+			stats.Hash,
+			stats.Time.Format(time.RFC3339),
+			stats.Text,
+			strconv.Itoa(stats.Replies),
+			strconv.Itoa(stats.Reactions),
+			strconv.Itoa(stats.Recasts),
+			strconv.Itoa(stats.Watches),
+			strconv.Itoa(stats.Engagement),
+			strconv.FormatFloat(stats.EngagementRate, 'f', -1, 64),
+			strconv.Itoa(stats.ReplyDepth),
+		}
+	})
+}
+
+// WriteEngagersCSV writes one row per top engager of the report.
+func (r *Report) WriteEngagersCSV(w io.Writer) error {
+	return writeCSV(w, []string{
+		"fid", "username", "reactions", "recasts", "replies", "total",
+	}, len(r.TopEngagers), func(i int) []string {
+		engager := r.TopEngagers[i]
+		return []string{
+			strconv.FormatUint(engager.Fid, 10),
+			engager.Username,
+			strconv.Itoa(engager.Reactions),
+			strconv.Itoa(engager.Recasts),
+			strconv.Itoa(engager.Replies),
+			strconv.Itoa(engager.Total),
+		}
+	})
+}
+
+// WriteHoursCSV writes the time of day histogram, one row per hour.
+func (r *Report) WriteHoursCSV(w io.Writer) error {
+	return writeCSV(w, []string{"hour", "casts", "engagement"}, len(r.Hours), func(i int) []string {
+		hour := r.Hours[i]
+		return []string{
+			strconv.Itoa(hour.Hour),
+			strconv.Itoa(hour.Casts),
+			strconv.Itoa(hour.Engagement),
+		}
+	})
+}
+
+func writeCSV(w io.Writer, header []string, n int, row func(i int) []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := writer.Write(row(i)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,333 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+const stateFile = "state.json"
+
+// Record is a single archived cast with the optional extras fetched for it.
+type Record struct {
+	Fid       uint64               `json:"fid"`
+	Cast      casts.Cast           `json:"cast"`
+	Thread    []casts.Cast         `json:"thread,omitempty"`
+	Reactions []reactions.Reaction `json:"reactions,omitempty"`
+	Recasters []users.User         `json:"recasters,omitempty"`
+}
+
+type Options struct {
+	// Threads fetches every cast of the threads the archived casts belong to.
+	Threads bool
+	// Reactions fetches the likes of every archived cast.
+	Reactions bool
+	// Recasters fetches the users who recast every archived cast.
+	Recasters bool
+	// PageSize is passed as limit to the paginated endpoints.
+	PageSize int
+}
+
+// fidState tracks the progress for a single fid so runs can be resumed.
+type fidState struct {
+	// Cursor is where the backfill of older casts continues from.
+	Cursor string `json:"cursor"`
+	// Complete is set once the backfill reached the oldest cast.
+	Complete bool `json:"complete"`
+	// Newest is the timestamp of the newest archived cast. New casts are
+	// fetched until this timestamp on the next run.
+	Newest uint64 `json:"newest"`
+}
+
+// Archiver stores casts of a set of fids under a directory, one JSONL file
+// per fid, and keeps enough state to resume and to only fetch new casts on
+// later runs.
+type Archiver struct {
+	casts     *casts.CastService
+	reactions *reactions.ReactionService
+	dir       string
+	options   Options
+	state     map[uint64]*fidState
+}
+
+// NewArchiver returns an archiver writing to dir. reactions may be nil unless
+// Reactions or Recasters is set.
+func NewArchiver(castService *casts.CastService, reactionService *reactions.ReactionService, dir string, options *Options) (*Archiver, error) {
+	a := &Archiver{
+		casts:     castService,
+		reactions: reactionService,
+		dir:       dir,
+		state:     make(map[uint64]*fidState),
+	}
+	if options != nil {
+		a.options = *options
+	}
+	if (a.options.Reactions || a.options.Recasters) && reactionService == nil {
+		return nil, errors.New("reaction service is required to archive reactions or recasters")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &a.state); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Archive fetches the casts of every fid that aren't archived yet.
+func (a *Archiver) Archive(fids ...uint64) error {
+	for _, fid := range fids {
+		if err := a.archiveFid(fid); err != nil {
+			return fmt.Errorf("archiving fid %d: %w", fid, err)
+		}
+	}
+	return nil
+}
+
+func (a *Archiver) archiveFid(fid uint64) error {
+	state, ok := a.state[fid]
+	if !ok {
+		state = &fidState{}
+		a.state[fid] = state
+	}
+	seen, err := a.hashes(fid)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.recordsPath(fid), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := terminateLastLine(file); err != nil {
+		return err
+	}
+	threads := make(map[string][]casts.Cast)
+
+	// New casts since the last run. The API returns the newest casts first so
+	// this stops at the first page that reaches already archived casts.
+	if state.Newest > 0 {
+		newest := state.Newest
+		cursor := ""
+		for {
+			page, next, err := a.casts.GetCastsByFid(fid, a.options.PageSize, cursor)
+			if err != nil {
+				return err
+			}
+			reached := false
+			for _, cast := range page {
+				if cast.Timestamp <= state.Newest {
+					reached = true
+					continue
+				}
+				if err := a.write(file, fid, cast, seen, threads); err != nil {
+					return err
+				}
+				if cast.Timestamp > newest {
+					newest = cast.Timestamp
+				}
+			}
+			if reached || next == "" || len(page) == 0 {
+				break
+			}
+			cursor = next
+		}
+		state.Newest = newest
+		if err := a.saveState(); err != nil {
+			return err
+		}
+	}
+
+	// Backfill older casts, saving the cursor after every page.
+	for !state.Complete {
+		page, next, err := a.casts.GetCastsByFid(fid, a.options.PageSize, state.Cursor)
+		if err != nil {
+			return err
+		}
+		for _, cast := range page {
+			if err := a.write(file, fid, cast, seen, threads); err != nil {
+				return err
+			}
+			if cast.Timestamp > state.Newest {
+				state.Newest = cast.Timestamp
+			}
+		}
+		state.Cursor = next
+		state.Complete = next == "" || len(page) == 0
+		if err := a.saveState(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archiver) write(file *os.File, fid uint64, cast casts.Cast, seen map[string]bool, threads map[string][]casts.Cast) error {
+	if seen[cast.Hash] {
+		return nil
+	}
+	record := Record{Fid: fid, Cast: cast}
+	if a.options.Threads && (cast.ParentHash != "" || (cast.Replies != nil && cast.Replies.Count > 0)) {
+		thread, ok := threads[cast.ThreadHash]
+		if !ok {
+			var err error
+			thread, err = a.casts.GetCastsInThread(cast.ThreadHash)
+			if err != nil {
+				return err
+			}
+			threads[cast.ThreadHash] = thread
+		}
+		record.Thread = thread
+	}
+	if a.options.Reactions {
+		reactions, err := a.allReactions(cast.Hash)
+		if err != nil {
+			return err
+		}
+		record.Reactions = reactions
+	}
+	if a.options.Recasters {
+		recasters, err := a.allRecasters(cast.Hash)
+		if err != nil {
+			return err
+		}
+		record.Recasters = recasters
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	seen[cast.Hash] = true
+	return nil
+}
+
+func (a *Archiver) allReactions(hash string) ([]reactions.Reaction, error) {
+	var all []reactions.Reaction
+	cursor := ""
+	for {
+		page, next, err := a.reactions.GetReactionsByCastHash(hash, a.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+func (a *Archiver) allRecasters(hash string) ([]users.User, error) {
+	var all []users.User
+	cursor := ""
+	for {
+		page, next, err := a.reactions.GetRecastersByCastHash(hash, a.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+func (a *Archiver) hashes(fid uint64) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	records, err := a.Records(fid)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		seen[record.Cast.Hash] = true
+	}
+	return seen, nil
+}
+
+// Records reads the archived records of a fid, newest first.
+func (a *Archiver) Records(fid uint64) ([]Record, error) {
+	file, err := os.Open(a.recordsPath(fid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A crash can leave a partial last line behind, it is fetched again.
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Cast.Timestamp > records[j].Cast.Timestamp
+	})
+	return records, nil
+}
+
+// terminateLastLine makes sure a partial line left by a crash doesn't get
+// merged with the next record.
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = file.Write([]byte{'\n'})
+	}
+	return err
+}
+
+func (a *Archiver) recordsPath(fid uint64) string {
+	return filepath.Join(a.dir, fmt.Sprintf("casts-%d.jsonl", fid))
+}
+
+func (a *Archiver) saveState() error {
+	data, err := json.MarshalIndent(a.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.dir, stateFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,78 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+func TestArchiveIsIncremental(t *testing.T) {
+	// Casts are served newest first, two per page.
+	timestamps := []int{3, 2, 1}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := 0
+		fmt.Sscanf(r.URL.Query().Get("cursor"), "%d", &start)
+		var items []string
+		for i := start; i < len(timestamps) && i < start+2; i++ {
+			items = append(items, fmt.Sprintf(`{"hash":"0x%d","text":"cast %d","timestamp":%d,"author":{"fid":1,"username":"alice"}}`, timestamps[i], timestamps[i], timestamps[i]))
+		}
+		next := ""
+		if start+2 < len(timestamps) {
+			next = fmt.Sprint(start + 2)
+		}
+		fmt.Fprintf(w, `{"result":{"casts":[%s]},"next":{"cursor":"%s"}}`, strings.Join(items, ","), next)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	castService := casts.NewCastService(account.NewAccountService(server.URL, ""), nil)
+	archiver, err := NewArchiver(castService, nil, dir, &Options{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Archive(1); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := archiver.Records(1)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	// A new cast shows up, a fresh archiver only appends that one.
+	timestamps = []int{4, 3, 2, 1}
+	archiver, err = NewArchiver(castService, nil, dir, &Options{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Archive(1); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = archiver.Records(1)
+	if len(records) != 4 || records[0].Cast.Hash != "0x4" {
+		t.Fatalf("Expected 4 records with 0x4 first, got %+v", records)
+	}
+
+	var csv bytes.Buffer
+	if err := archiver.ExportCSV(&csv, 1); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != 5 {
+		t.Errorf("Expected header and 4 rows, got %d lines", lines)
+	}
+	bundle := filepath.Join(dir, "bundle")
+	if err := archiver.ExportBundle(bundle, 1); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(bundle, "1.md"))
+	if err != nil || !strings.Contains(string(page), "> cast 4") {
+		t.Errorf("Expected the markdown page to quote the casts, got %q (%v)", page, err)
+	}
+}
@@ -0,0 +1,288 @@
+package archive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+var csvHeader = []string{
+	"fid", "hash", "threadHash", "parentHash", "authorFid", "authorUsername",
+	"timestamp", "text", "replies", "reactions", "recasts", "watches",
+}
+
+// ExportJSONL writes the records of the given fids as one JSON object per line.
+func (a *Archiver) ExportJSONL(w io.Writer, fids ...uint64) error {
+	encoder := json.NewEncoder(w)
+	for _, fid := range fids {
+		records, err := a.Records(fid)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportCSV writes one row per archived cast. Threads, reactions and recasters
+// are left out, only their counts are included.
+func (a *Archiver) ExportCSV(w io.Writer, fids ...uint64) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, fid := range fids {
+		records, err := a.Records(fid)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := writer.Write(csvRow(record)); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvRow(record Record) []string {
+	cast := record.Cast
+	authorFid, authorUsername := "", ""
+	if cast.Author != nil {
+		authorFid = strconv.FormatUint(cast.Author.Fid, 10)
+		authorUsername = cast.Author.Username
+	}
+	replies, reactions, recasts, watches := 0, 0, 0, 0
+	if cast.Replies != nil {
+		replies = cast.Replies.Count
+	}
+	if cast.Reactions != nil {
+		reactions = cast.Reactions.Count
+	}
+	if cast.Recasts != nil {
+		recasts = cast.Recasts.Count
+	}
+	if cast.Watches != nil {
+		watches = cast.Watches.Count
+	}
+	return []string{
+		strconv.FormatUint(record.Fid, 10),
+		cast.Hash,
+		cast.ThreadHash,
+		cast.ParentHash,
+		authorFid,
+		authorUsername,
+		timestamp(cast.Timestamp).Format(time.RFC3339),
+		cast.Text,
+		strconv.Itoa(replies),
+		strconv.Itoa(reactions),
+		strconv.Itoa(recasts),
+		strconv.Itoa(watches),
+	}
+}
+
+// ExportBundle writes a browsable copy of the archive to dir: an index and a
+// page per fid, both as Markdown and HTML.
+func (a *Archiver) ExportBundle(dir string, fids ...uint64) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var pages []bundlePage
+	for _, fid := range fids {
+		records, err := a.Records(fid)
+		if err != nil {
+			return err
+		}
+		page := newBundlePage(fid, records)
+		pages = append(pages, page)
+		if err := writeTemplate(filepath.Join(dir, fmt.Sprintf("%d.md", fid)), markdownPage, page); err != nil {
+			return err
+		}
+		if err := writeHTML(filepath.Join(dir, fmt.Sprintf("%d.html", fid)), htmlPage, page); err != nil {
+			return err
+		}
+	}
+	if err := writeTemplate(filepath.Join(dir, "index.md"), markdownIndex, pages); err != nil {
+		return err
+	}
+	return writeHTML(filepath.Join(dir, "index.html"), htmlIndex, pages)
+}
+
+type bundlePage struct {
+	Fid      uint64
+	Username string
+	Casts    []bundleCast
+}
+
+type bundleCast struct {
+	Hash      string
+	Time      string
+	Text      string
+	Replies   int
+	Reactions int
+	Recasts   int
+	Likers    []string
+	Recasters []string
+	Thread    string
+	ThreadMd  string
+}
+
+func newBundlePage(fid uint64, records []Record) bundlePage {
+	page := bundlePage{Fid: fid}
+	for _, record := range records {
+		cast := record.Cast
+		if page.Username == "" && cast.Author != nil {
+			page.Username = cast.Author.Username
+		}
+		item := bundleCast{
+			Hash: cast.Hash,
+			Time: timestamp(cast.Timestamp).Format("2006-01-02 15:04 MST"),
+			Text: cast.Text,
+		}
+		if cast.Replies != nil {
+			item.Replies = cast.Replies.Count
+		}
+		if cast.Reactions != nil {
+			item.Reactions = cast.Reactions.Count
+		}
+		if cast.Recasts != nil {
+			item.Recasts = cast.Recasts.Count
+		}
+		for _, reaction := range record.Reactions {
+			if reaction.Reactor != nil {
+				item.Likers = append(item.Likers, "@"+reaction.Reactor.Username)
+			}
+		}
+		for _, recaster := range record.Recasters {
+			item.Recasters = append(item.Recasters, "@"+recaster.Username)
+		}
+		if len(record.Thread) > 0 {
+			if thread, err := casts.NewThread(record.Thread); err == nil {
+				item.Thread = thread.Text()
+				item.ThreadMd = thread.Markdown()
+			}
+		}
+		page.Casts = append(page.Casts, item)
+	}
+	return page
+}
+
+func timestamp(ms uint64) time.Time {
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+var funcs = map[string]interface{}{
+	"join": strings.Join,
+	"quote": func(text string) string {
+		return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+	},
+}
+
+var markdownIndex = `# Cast archive
+{{range .}}
+- [{{if .Username}}@{{.Username}}{{else}}fid {{.Fid}}{{end}}]({{.Fid}}.md) ({{len .Casts}} casts)
+{{- end}}
+`
+
+var markdownPage = `# {{if .Username}}@{{.Username}}{{else}}fid {{.Fid}}{{end}}
+
+[Back to index](index.md)
+{{range .Casts}}
+## {{.Time}}
+
+{{quote .Text}}
+
+{{.Replies}} replies, {{.Reactions}} likes, {{.Recasts}} recasts · ` + "`{{.Hash}}`" + `
+{{- if .Likers}}
+
+Liked by {{join .Likers ", "}}
+{{- end}}
+{{- if .Recasters}}
+
+Recast by {{join .Recasters ", "}}
+{{- end}}
+{{- if .ThreadMd}}
+
+<details><summary>Thread</summary>
+
+{{.ThreadMd}}
+</details>
+{{- end}}
+{{end}}`
+
+var htmlIndex = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Cast archive</title></head>
+<body>
+<h1>Cast archive</h1>
+<ul>
+{{- range .}}
+<li><a href="{{.Fid}}.html">{{if .Username}}@{{.Username}}{{else}}fid {{.Fid}}{{end}}</a> ({{len .Casts}} casts)</li>
+{{- end}}
+</ul>
+</body></html>
+`
+
+var htmlPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{if .Username}}@{{.Username}}{{else}}fid {{.Fid}}{{end}}</title></head>
+<body>
+<h1>{{if .Username}}@{{.Username}}{{else}}fid {{.Fid}}{{end}}</h1>
+<p><a href="index.html">Back to index</a></p>
+{{- range .Casts}}
+<article id="{{.Hash}}">
+<h2>{{.Time}}</h2>
+<blockquote style="white-space: pre-wrap">{{.Text}}</blockquote>
+<p>{{.Replies}} replies, {{.Reactions}} likes, {{.Recasts}} recasts · <code>{{.Hash}}</code></p>
+{{- if .Likers}}
+<p>Liked by {{join .Likers ", "}}</p>
+{{- end}}
+{{- if .Recasters}}
+<p>Recast by {{join .Recasters ", "}}</p>
+{{- end}}
+{{- if .Thread}}
+<details><summary>Thread</summary><pre>{{.Thread}}</pre></details>
+{{- end}}
+</article>
+{{- end}}
+</body></html>
+`
+
+func writeTemplate(path, text string, data interface{}) error {
+	tmpl, err := texttemplate.New(filepath.Base(path)).Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return tmpl.Execute(file, data)
+}
+
+func writeHTML(path, text string, data interface{}) error {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return tmpl.Execute(file, data)
+}
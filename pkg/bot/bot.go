@@ -0,0 +1,282 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/notifications"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+)
+
+// Handler handles a notification routed to it.
+type Handler func(c *Context) error
+
+// Middleware wraps a handler, e.g. to filter or throttle notifications.
+type Middleware func(next Handler) Handler
+
+// Context carries the triggering notification to a handler.
+type Context struct {
+	context.Context
+	Notification *notifications.Notification
+	// Cast is the cast of the notification, nil for follows.
+	Cast *casts.Cast
+	// Text is the cast text with the leading mention of the bot removed.
+	Text string
+	// Args are the words after a command.
+	Args []string
+	// Matches holds the submatches of a regexp route.
+	Matches []string
+	bot     *Bot
+}
+
+// ActorFid returns the fid of the user who triggered the notification.
+func (c *Context) ActorFid() uint64 {
+	if c.Notification.Actor != nil {
+		return c.Notification.Actor.Fid
+	}
+	if c.Cast != nil && c.Cast.Author != nil {
+		return c.Cast.Author.Fid
+	}
+	return 0
+}
+
+// Reply publishes a reply to the triggering cast.
+func (c *Context) Reply(text string) (*casts.Cast, error) {
+	if c.Cast == nil || c.Cast.Author == nil {
+		return nil, errors.New("notification has no cast to reply to")
+	}
+	return c.bot.casts.PublishReplyCast(text, c.Cast.Author.Fid, c.Cast.Hash)
+}
+
+// Replyf is Reply with fmt.Sprintf formatting.
+func (c *Context) Replyf(format string, args ...interface{}) (*casts.Cast, error) {
+	return c.Reply(fmt.Sprintf(format, args...))
+}
+
+func (c *Context) Like() error {
+	if c.Cast == nil {
+		return errors.New("notification has no cast to like")
+	}
+	if c.bot.reactions == nil {
+		return errors.New("reaction service is not set")
+	}
+	_, err := c.bot.reactions.ReactToCast(c.Cast.Hash)
+	return err
+}
+
+func (c *Context) Recast() error {
+	if c.Cast == nil {
+		return errors.New("notification has no cast to recast")
+	}
+	if c.bot.reactions == nil {
+		return errors.New("reaction service is not set")
+	}
+	_, err := c.bot.reactions.RecastCast(c.Cast.Hash)
+	return err
+}
+
+type route struct {
+	command     string
+	pattern     *regexp.Regexp
+	kinds       []notifications.Kind
+	mentionOnly bool
+	handler     Handler
+}
+
+type RouteOption func(r *route)
+
+// MentionOnly only matches casts that mention the bot.
+func MentionOnly() RouteOption {
+	return func(r *route) {
+		r.mentionOnly = true
+	}
+}
+
+// Kinds only matches notifications of the given kinds.
+func Kinds(kinds ...notifications.Kind) RouteOption {
+	return func(r *route) {
+		r.kinds = kinds
+	}
+}
+
+type Options struct {
+	// Username of the bot, used to detect and strip mentions.
+	Username string
+	// WatchOptions are passed to NotificationService.Watch.
+	WatchOptions *notifications.WatchOptions
+	// OnError is called with handler errors. Defaults to ignoring them.
+	OnError func(c *Context, err error)
+}
+
+type Bot struct {
+	notifications *notifications.NotificationService
+	casts         *casts.CastService
+	reactions     *reactions.ReactionService
+	options       Options
+	routes        []*route
+	fallback      Handler
+	middleware    []Middleware
+}
+
+func NewBot(notificationService *notifications.NotificationService, castService *casts.CastService, reactionService *reactions.ReactionService, options *Options) *Bot {
+	b := &Bot{
+		notifications: notificationService,
+		casts:         castService,
+		reactions:     reactionService,
+	}
+	if options != nil {
+		b.options = *options
+	}
+	b.options.Username = strings.TrimPrefix(strings.ToLower(b.options.Username), "@")
+	return b
+}
+
+// Use adds middleware around every route. Middleware added first runs first.
+func (b *Bot) Use(middleware ...Middleware) {
+	b.middleware = append(b.middleware, middleware...)
+}
+
+// Command routes casts whose first word, after the bot mention, is prefix,
+// e.g. "/weather" or "price".
+func (b *Bot) Command(prefix string, handler Handler, options ...RouteOption) {
+	b.add(&route{command: strings.ToLower(prefix), handler: handler}, options)
+}
+
+// Match routes casts whose text matches pattern.
+func (b *Bot) Match(pattern *regexp.Regexp, handler Handler, options ...RouteOption) {
+	b.add(&route{pattern: pattern, handler: handler}, options)
+}
+
+// On routes every notification of the given kind, e.g. follows.
+func (b *Bot) On(kind notifications.Kind, handler Handler, options ...RouteOption) {
+	b.add(&route{kinds: []notifications.Kind{kind}, handler: handler}, options)
+}
+
+// Fallback handles notifications no route matched.
+func (b *Bot) Fallback(handler Handler) {
+	b.fallback = handler
+}
+
+func (b *Bot) add(r *route, options []RouteOption) {
+	for _, option := range options {
+		option(r)
+	}
+	b.routes = append(b.routes, r)
+}
+
+// Run watches notifications and dispatches them until ctx is done.
+func (b *Bot) Run(ctx context.Context, interval time.Duration) error {
+	watch, err := b.notifications.Watch(ctx, interval, b.options.WatchOptions)
+	if err != nil {
+		return err
+	}
+	for notification := range watch {
+		n := notification
+		b.Handle(ctx, &n)
+	}
+	return ctx.Err()
+}
+
+// Handle dispatches a single notification to the first matching route. It is
+// what Run calls for every notification and can be used to drive the bot
+// from other sources.
+func (b *Bot) Handle(ctx context.Context, n *notifications.Notification) {
+	c := &Context{
+		Context:      ctx,
+		Notification: n,
+		Cast:         n.Content.Cast,
+		bot:          b,
+	}
+	if c.Cast != nil {
+		c.Text = b.stripMention(c.Cast.Text)
+	}
+	handler := b.fallback
+	for _, r := range b.routes {
+		if b.matches(r, c) {
+			handler = r.handler
+			break
+		}
+	}
+	if handler == nil {
+		return
+	}
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		handler = b.middleware[i](handler)
+	}
+	if err := handler(c); err != nil && b.options.OnError != nil {
+		b.options.OnError(c, err)
+	}
+}
+
+func (b *Bot) matches(r *route, c *Context) bool {
+	c.Args, c.Matches = nil, nil
+	if len(r.kinds) > 0 && !containsKind(r.kinds, c.Notification.Kind()) {
+		return false
+	}
+	if r.mentionOnly && !b.mentions(c) {
+		return false
+	}
+	if r.command != "" {
+		fields := strings.Fields(c.Text)
+		if c.Cast == nil || len(fields) == 0 || strings.ToLower(fields[0]) != r.command {
+			return false
+		}
+		c.Args = fields[1:]
+	}
+	if r.pattern != nil {
+		if c.Cast == nil {
+			return false
+		}
+		matches := r.pattern.FindStringSubmatch(c.Text)
+		if matches == nil {
+			return false
+		}
+		c.Matches = matches
+	}
+	return true
+}
+
+func (b *Bot) mentions(c *Context) bool {
+	if c.Notification.Kind() == notifications.KindMention {
+		return true
+	}
+	if c.Cast == nil || b.options.Username == "" {
+		return false
+	}
+	for _, field := range strings.Fields(strings.ToLower(c.Cast.Text)) {
+		if strings.TrimRight(field, ".,:;!?") == "@"+b.options.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// stripMention removes a leading "@bot" so commands work with and without it.
+func (b *Bot) stripMention(text string) string {
+	text = strings.TrimSpace(text)
+	if b.options.Username == "" {
+		return text
+	}
+	fields := strings.SplitN(text, " ", 2)
+	if strings.ToLower(strings.TrimRight(fields[0], ",:")) != "@"+b.options.Username {
+		return text
+	}
+	if len(fields) == 1 {
+		return ""
+	}
+	return strings.TrimSpace(fields[1])
+}
+
+func containsKind(kinds []notifications.Kind, kind notifications.Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/notifications"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+const testNotifications = `{"result":{"notifications":[
+	{"id":"3","type":"cast-mention","timestamp":3,"actor":{"fid":9},"content":{"cast":{"hash":"0x3","text":"@echobot echo blocked","author":{"fid":9}}}},
+	{"id":"2","type":"cast-reply","timestamp":2,"actor":{"fid":7},"content":{"cast":{"hash":"0x2","text":"what is 2+2","author":{"fid":7}}}},
+	{"id":"1","type":"cast-mention","timestamp":1,"actor":{"fid":7},"content":{"cast":{"hash":"0x1","text":"@echobot echo hello world","author":{"fid":7}}}}
+]}}`
+
+func TestBotRun(t *testing.T) {
+	var mu sync.Mutex
+	var replies []string
+	var liked []string
+	polls := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v2/notifications":
+			w.Write([]byte(testNotifications))
+			select {
+			case polls <- struct{}{}:
+			default:
+			}
+		case "/v2/casts":
+			var request struct {
+				Text   string `json:"text"`
+				Parent struct {
+					Hash string `json:"hash"`
+				} `json:"parent"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			replies = append(replies, request.Parent.Hash+":"+request.Text)
+			w.Write([]byte(`{"result":{"cast":{"hash":"0xreply"}}}`))
+		case "/v2/cast-likes":
+			var request struct {
+				CastHash string `json:"castHash"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			liked = append(liked, request.CastHash)
+			w.Write([]byte(`{"result":{"like":{"type":"like"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	account := account.NewAccountService(server.URL, "")
+	bot := NewBot(notifications.NewNotificationService(account), casts.NewCastService(account, nil), reactions.NewReactionService(account), &Options{Username: "echobot"})
+	bot.Use(Allowlist(7), Dedup(time.Minute))
+	var args [][]string
+	bot.Command("echo", func(c *Context) error {
+		args = append(args, c.Args)
+		_, err := c.Reply(strings.Join(c.Args, " "))
+		return err
+	}, MentionOnly())
+	bot.Match(regexp.MustCompile(`(\d+)\+(\d+)`), func(c *Context) error {
+		return c.Like()
+	})
+
+	// A poll only starts once Run received everything from the previous one,
+	// so after a few polls every notification was dispatched.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	for i := 0; i < 4; i++ {
+		select {
+		case <-polls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for polls")
+		}
+	}
+	cancel()
+	<-done
+
+	if len(args) != 1 || strings.Join(args[0], "|") != "hello|world" {
+		t.Errorf("Expected the echo command to get [hello world] as args, got %q", args)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(replies) != 1 || replies[0] != "0x1:hello world" {
+		t.Errorf("Expected a single echo reply to 0x1, got %v", replies)
+	}
+	if len(liked) != 1 || liked[0] != "0x2" {
+		t.Errorf("Expected 0x2 to be liked once, got %v", liked)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	var handled []string
+	handler := Dedup(time.Minute)(func(c *Context) error {
+		handled = append(handled, c.Notification.Id)
+		return nil
+	})
+	cast := &casts.Cast{Hash: "0xb", Author: &users.User{Fid: 1}}
+	for _, n := range []*notifications.Notification{
+		{Id: "1", Type: "cast-mention", Actor: &users.User{Fid: 7}},
+		{Id: "2", Type: "cast-reply", Actor: &users.User{Fid: 7}},
+		{Id: "3", Type: "like", Actor: &users.User{Fid: 8}},
+		{Id: "4", Type: "like", Actor: &users.User{Fid: 9}},
+		{Id: "5", Type: "recast", Actor: &users.User{Fid: 9}},
+		{Id: "6", Type: "like", Actor: &users.User{Fid: 9}},
+		{Id: "7", Type: "follow", Actor: &users.User{Fid: 9}},
+	} {
+		c := &Context{Notification: n}
+		if n.Kind() != notifications.KindFollow {
+			c.Cast = cast
+		}
+		handler(c)
+	}
+	if strings.Join(handled, ",") != "1,3,4,5,7" {
+		t.Errorf("Expected the reply to a mention and the repeated like to be dropped, got %v", handled)
+	}
+}
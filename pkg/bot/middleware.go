@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/notifications"
+)
+
+// ErrRateLimited is returned by RateLimit when a user is over the limit.
+var ErrRateLimited = errors.New("rate limited")
+
+// Allowlist only lets notifications from the given fids through. Others are
+// dropped silently.
+func Allowlist(fids ...uint64) Middleware {
+	allowed := make(map[uint64]bool, len(fids))
+	for _, fid := range fids {
+		allowed[fid] = true
+	}
+	return func(next Handler) Handler {
+		return func(c *Context) error {
+			if !allowed[c.ActorFid()] {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimit allows each user at most limit handled notifications per window.
+// Notifications over the limit are dropped and reported as ErrRateLimited.
+func RateLimit(limit int, window time.Duration) Middleware {
+	var mu sync.Mutex
+	hits := make(map[uint64][]time.Time)
+	return func(next Handler) Handler {
+		return func(c *Context) error {
+			fid := c.ActorFid()
+			now := time.Now()
+			mu.Lock()
+			recent := hits[fid][:0]
+			for _, hit := range hits[fid] {
+				if now.Sub(hit) < window {
+					recent = append(recent, hit)
+				}
+			}
+			if len(recent) >= limit {
+				hits[fid] = recent
+				mu.Unlock()
+				return ErrRateLimited
+			}
+			hits[fid] = append(recent, now)
+			mu.Unlock()
+			return next(c)
+		}
+	}
+}
+
+// Dedup drops notifications that repeat one already handled within window,
+// e.g. a cast that both mentions the bot and replies to it. Likes and recasts
+// of the same cast by different users are all handled.
+func Dedup(window time.Duration) Middleware {
+	var mu sync.Mutex
+	handled := make(map[string]time.Time)
+	return func(next Handler) Handler {
+		return func(c *Context) error {
+			key := dedupKey(c)
+			now := time.Now()
+			mu.Lock()
+			for k, at := range handled {
+				if now.Sub(at) >= window {
+					delete(handled, k)
+				}
+			}
+			if _, ok := handled[key]; ok {
+				mu.Unlock()
+				return nil
+			}
+			handled[key] = now
+			mu.Unlock()
+			return next(c)
+		}
+	}
+}
+
+func dedupKey(c *Context) string {
+	if c.Cast == nil {
+		return c.Notification.Id
+	}
+	kind := c.Notification.Kind()
+	if kind == notifications.KindMention {
+		// A mention in a reply to the bot is the same cast as the reply.
+		kind = notifications.KindReply
+	}
+	return fmt.Sprintf("%s/%d/%s", kind, c.ActorFid(), c.Cast.Hash)
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kind groups cached values for TTLs and stats.
+type Kind string
+
+const (
+	KindUser Kind = "user"
+	// KindUsername maps usernames to fids, the users are cached by fid.
+	KindUsername Kind = "username"
+	KindCast     Kind = "cast"
+	KindCustody  Kind = "custody"
+)
+
+var DefaultTTLs = map[Kind]time.Duration{
+	KindUser:     time.Minute,
+	KindUsername: time.Hour,
+	KindCast:     time.Minute,
+	KindCustody:  time.Hour,
+}
+
+type Options struct {
+	// Store defaults to an LRU of 10000 entries.
+	Store Store
+	// TTLs overrides DefaultTTLs per kind. Kinds in neither are cached for a
+	// minute.
+	TTLs map[Kind]time.Duration
+}
+
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Errors are store failures, counted as misses too.
+	Errors uint64
+}
+
+// Cache stores JSON encoded values by kind and key. A nil *Cache is valid and
+// never hits, so services can call it unconditionally.
+type Cache struct {
+	store Store
+	ttls  map[Kind]time.Duration
+	mu    sync.Mutex
+	stats map[Kind]*Stats
+}
+
+func New(options *Options) *Cache {
+	c := &Cache{
+		ttls:  make(map[Kind]time.Duration),
+		stats: make(map[Kind]*Stats),
+	}
+	for kind, ttl := range DefaultTTLs {
+		c.ttls[kind] = ttl
+	}
+	if options != nil {
+		c.store = options.Store
+		for kind, ttl := range options.TTLs {
+			c.ttls[kind] = ttl
+		}
+	}
+	if c.store == nil {
+		c.store = NewLRU(10000)
+	}
+	return c
+}
+
+// Key builds a key from a fid.
+func Key(fid uint64) string {
+	return strconv.FormatUint(fid, 10)
+}
+
+func storeKey(kind Kind, key string) string {
+	return string(kind) + ":" + key
+}
+
+// Get decodes the cached value into v and reports whether there was one.
+func (c *Cache) Get(kind Kind, key string, v interface{}) bool {
+	if c == nil {
+		return false
+	}
+	data, ok, err := c.store.Get(storeKey(kind, key))
+	if ok && err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.statsOf(kind)
+	switch {
+	case err != nil:
+		stats.Errors++
+		stats.Misses++
+		return false
+	case !ok:
+		stats.Misses++
+		return false
+	}
+	stats.Hits++
+	return true
+}
+
+func (c *Cache) Set(kind Kind, key string, v interface{}) {
+	if c == nil {
+		return
+	}
+	ttl, ok := c.ttls[kind]
+	if !ok {
+		ttl = time.Minute
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = c.store.Set(storeKey(kind, key), data, ttl)
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.statsOf(kind).Errors++
+		c.mu.Unlock()
+	}
+}
+
+// Delete invalidates a value, e.g. after a write changed it.
+func (c *Cache) Delete(kind Kind, key string) {
+	if c == nil {
+		return
+	}
+	if err := c.store.Delete(storeKey(kind, key)); err != nil {
+		c.mu.Lock()
+		c.statsOf(kind).Errors++
+		c.mu.Unlock()
+	}
+}
+
+// Stats returns the counters of every kind used so far.
+func (c *Cache) Stats() map[Kind]Stats {
+	stats := make(map[Kind]Stats)
+	if c == nil {
+		return stats
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for kind, s := range c.stats {
+		stats[kind] = *s
+	}
+	return stats
+}
+
+func (c *Cache) statsOf(kind Kind) *Stats {
+	stats, ok := c.stats[kind]
+	if !ok {
+		stats = &Stats{}
+		c.stats[kind] = stats
+	}
+	return stats
+}
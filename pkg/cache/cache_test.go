@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Unix(0, 0)
+	lru := NewLRU(2)
+	lru.clock = func() time.Time { return now }
+
+	lru.Set("a", []byte("1"), time.Minute)
+	lru.Set("b", []byte("2"), 0)
+	lru.Get("a")
+	lru.Set("c", []byte("3"), 0)
+	if _, ok, _ := lru.Get("b"); ok {
+		t.Error("Expected b to be evicted as the least recently used")
+	}
+	if value, ok, _ := lru.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("Expected a to be kept, got %q", value)
+	}
+	now = now.Add(time.Minute)
+	if _, ok, _ := lru.Get("a"); ok {
+		t.Error("Expected a to expire")
+	}
+	if _, ok, _ := lru.Get("c"); !ok || lru.Len() != 1 {
+		t.Errorf("Expected only c without a TTL to remain, got %d entries", lru.Len())
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c := New(&Options{TTLs: map[Kind]time.Duration{KindUser: time.Hour}})
+	var value struct{ Name string }
+	if c.Get(KindUser, Key(1), &value) {
+		t.Fatal("Expected a miss on an empty cache")
+	}
+	c.Set(KindUser, Key(1), struct{ Name string }{"a"})
+	if !c.Get(KindUser, Key(1), &value) || value.Name != "a" {
+		t.Errorf("Expected a hit with a, got %+v", value)
+	}
+	c.Delete(KindUser, Key(1))
+	c.Get(KindUser, Key(1), &value)
+	if stats := c.Stats()[KindUser]; stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %+v", stats)
+	}
+
+	var none *Cache
+	none.Set(KindCast, "0x1", 1)
+	if none.Get(KindCast, "0x1", &value) || len(none.Stats()) != 0 {
+		t.Error("Expected a nil cache to never hit")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
 	"github.com/ertan/go-farcaster/pkg/registry"
 	"github.com/ertan/go-farcaster/pkg/users"
 )
@@ -13,6 +14,7 @@ import (
 type CastService struct {
 	account  *account.AccountService
 	registry *registry.RegistryService
+	cache    *cache.Cache
 }
 
 type Cast struct {
@@ -45,10 +47,10 @@ type Recasts struct {
 }
 
 type Recaster struct {
-	Fid         uint64 `json:"fid"`
-	Username    string `json:"username"`
-	DisplayName string `json:"displayName"`
-	RecastHash  string `json:"recastHash"`
+	Fid         users.Fid `json:"fid"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName"`
+	RecastHash  string    `json:"recastHash"`
 }
 
 type Watches struct {
@@ -80,7 +82,16 @@ func NewCastService(account *account.AccountService, registry *registry.Registry
 	}
 }
 
+// SetCache caches casts fetched by hash.
+func (c *CastService) SetCache(castCache *cache.Cache) {
+	c.cache = castCache
+}
+
 func (c *CastService) GetCastByHash(hash string) (*Cast, error) {
+	var cached Cast
+	if c.cache.Get(cache.KindCast, hash, &cached) {
+		return &cached, nil
+	}
 	type CastResponse struct {
 		Result struct {
 			Cast *Cast `json:"cast"`
@@ -102,6 +113,9 @@ func (c *CastService) GetCastByHash(hash string) (*Cast, error) {
 			// TODO(ertan): Find a better solution to pass the errors here.
 			return nil, errors.New(response.Errors[0].Message)
 		}
+		if response.Result.Cast != nil {
+			c.cache.Set(cache.KindCast, hash, response.Result.Cast)
+		}
 		return response.Result.Cast, nil
 	}
 	return nil, errors.New("Error fetching cast")
@@ -232,7 +246,12 @@ func (c *CastService) PublishReplyCast(text string, fid uint64, hash string) (*C
 	if err != nil {
 		return nil, err
 	}
-	return c.publishCast(requestBytes)
+	cast, err := c.publishCast(requestBytes)
+	if err == nil {
+		// The reply count of the parent changed.
+		c.cache.Delete(cache.KindCast, hash)
+	}
+	return cast, err
 }
 
 func (c *CastService) DeleteCast(castHash string) error {
@@ -263,6 +282,7 @@ func (c *CastService) DeleteCast(castHash string) error {
 	if !response.Result.Success {
 		return errors.New("failed to delete cast")
 	}
+	c.cache.Delete(cache.KindCast, castHash)
 	return nil
 }
 
@@ -285,3 +305,78 @@ func (c *CastService) GetRecentCasts(limit int) ([]Cast, string, error) {
 	}
 	return nil, "", err
 }
+
+func (c *CastService) WatchCast(hash string) error {
+	return c.setWatch("PUT", hash)
+}
+
+func (c *CastService) UnwatchCast(hash string) error {
+	return c.setWatch("DELETE", hash)
+}
+
+func (c *CastService) setWatch(method, hash string) error {
+	type WatchRequest struct {
+		CastHash string `json:"castHash"`
+	}
+	type WatchResponse struct {
+		Result struct {
+			Success bool `json:"success"`
+		} `json:"result"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	request := WatchRequest{
+		CastHash: hash,
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	responseBytes, err := c.account.SendRequest(method, "/v2/cast-watches", nil, requestBytes)
+	if err != nil {
+		return err
+	}
+	var response WatchResponse
+	if err := json.Unmarshal(responseBytes, &response); err == nil {
+		if len(response.Errors) > 0 {
+			// TODO(ertan): Find a better solution to pass the errors here.
+			return errors.New(response.Errors[0].Message)
+		}
+		if response.Result.Success {
+			c.cache.Delete(cache.KindCast, hash)
+			return nil
+		}
+	}
+	if method == "DELETE" {
+		return errors.New("Error unwatching cast")
+	}
+	return errors.New("Error watching cast")
+}
+
+// GetWatchedCasts lists the casts the viewer watches.
+//
+// Experimental: /v2/watched-casts isn't part of the documented v2 API, it
+// mirrors what the Warpcast client requests and may change or go away.
+func (c *CastService) GetWatchedCasts(limit int, cursor string) ([]Cast, string, error) {
+	params := map[string]interface{}{}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	if cursor != "" {
+		params["cursor"] = cursor
+	}
+	responseBytes, err := c.account.SendRequest("GET", "/v2/watched-casts", params, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var response CastsResponse
+	if err := json.Unmarshal(responseBytes, &response); err == nil {
+		if len(response.Errors) > 0 {
+			// TODO(ertan): Find a better solution to pass the errors here.
+			return nil, "", errors.New(response.Errors[0].Message)
+		}
+		return response.Result.Casts, response.Next.Cursor, nil
+	}
+	return nil, "", errors.New("Error fetching watched casts")
+}
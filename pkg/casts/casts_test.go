@@ -0,0 +1,64 @@
+package casts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestWatchCast(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/watched-casts" {
+			fmt.Fprintf(w, `{"result":{"casts":[{"hash":"0xa"}]},"next":{"cursor":"%s-next"}}`, r.URL.Query().Get("limit"))
+			return
+		}
+		if r.URL.Path != "/v2/cast-watches" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		var request struct {
+			CastHash string `json:"castHash"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, r.Method+" "+request.CastHash)
+		switch request.CastHash {
+		case "0xa":
+			fmt.Fprint(w, `{"result":{"success":true}}`)
+		case "0xb":
+			fmt.Fprint(w, `{"errors":[{"message":"cast not found"}]}`)
+		default:
+			fmt.Fprint(w, `{"result":{"success":false}}`)
+		}
+	}))
+	defer server.Close()
+	castService := NewCastService(account.NewAccountService(server.URL, ""), nil)
+
+	if err := castService.WatchCast("0xa"); err != nil {
+		t.Errorf("Expected the watch to succeed, got %v", err)
+	}
+	if err := castService.UnwatchCast("0xa"); err != nil {
+		t.Errorf("Expected the unwatch to succeed, got %v", err)
+	}
+	if err := castService.WatchCast("0xb"); err == nil || err.Error() != "cast not found" {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+	if err := castService.UnwatchCast("0xc"); err == nil || err.Error() != "Error unwatching cast" {
+		t.Errorf("Expected an unsuccessful unwatch to fail, got %v", err)
+	}
+	expected := []string{"PUT 0xa", "DELETE 0xa", "PUT 0xb", "DELETE 0xc"}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+
+	watched, cursor, err := castService.GetWatchedCasts(5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watched) != 1 || cursor != "5-next" {
+		t.Errorf("Expected a page of watched casts with a cursor, got %+v and %q", watched, cursor)
+	}
+}
@@ -0,0 +1,294 @@
+package casts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ertan/go-farcaster/pkg/cache"
+	"github.com/ertan/go-farcaster/pkg/registry"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+const (
+	// MaxCastBytes is the maximum length of a cast's text in bytes.
+	MaxCastBytes = 320
+	// MaxUsernameLength is the maximum length of an fname.
+	MaxUsernameLength = users.MaxUsernameLength
+	// MaxEmbeds is the number of links clients render as embeds.
+	MaxEmbeds = 2
+)
+
+var linkRegexp = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+var embedExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// MentionResolver maps an fname to its fid.
+type MentionResolver func(username string) (uint64, error)
+
+func UserMentionResolver(u *users.UserService) MentionResolver {
+	return func(username string) (uint64, error) {
+		user, err := u.GetUserByUsername(username)
+		if err != nil {
+			return 0, err
+		}
+		if user == nil {
+			return 0, errors.New("user not found")
+		}
+		return user.Fid, nil
+	}
+}
+
+func RegistryMentionResolver(r *registry.RegistryService) MentionResolver {
+	return func(username string) (uint64, error) {
+		return r.GetFidByFname(username)
+	}
+}
+
+type Mention struct {
+	Username string `json:"username"`
+	Fid      uint64 `json:"fid"`
+	// Offset is the byte offset of the '@' in the text.
+	Offset int `json:"offset"`
+}
+
+type Link struct {
+	Url    string `json:"url"`
+	Offset int    `json:"offset"`
+	// Embed is set for links clients render inline, like images and casts.
+	Embed bool `json:"embed"`
+}
+
+// Draft is a cast text that passed validation, with its mentions and links.
+type Draft struct {
+	Text     string    `json:"text"`
+	Mentions []Mention `json:"mentions"`
+	Links    []Link    `json:"links"`
+}
+
+func (d *Draft) Embeds() []string {
+	var embeds []string
+	for _, link := range d.Links {
+		if link.Embed {
+			embeds = append(embeds, link.Url)
+		}
+	}
+	return embeds
+}
+
+type ValidationErrorKind string
+
+const (
+	ErrEmptyText         ValidationErrorKind = "empty_text"
+	ErrInvalidUTF8       ValidationErrorKind = "invalid_utf8"
+	ErrTextTooLong       ValidationErrorKind = "text_too_long"
+	ErrInvalidMention    ValidationErrorKind = "invalid_mention"
+	ErrUnresolvedMention ValidationErrorKind = "unresolved_mention"
+	ErrInvalidLink       ValidationErrorKind = "invalid_link"
+	ErrTooManyEmbeds     ValidationErrorKind = "too_many_embeds"
+)
+
+type ValidationError struct {
+	Kind    ValidationErrorKind
+	Offset  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s at byte %d: %s", e.Kind, e.Offset, e.Message)
+}
+
+// ValidationErrors collects every problem found in a text so callers can
+// report them all at once.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Has reports whether an error of the given kind was found.
+func (e ValidationErrors) Has(kind ValidationErrorKind) bool {
+	for _, err := range e {
+		if err.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type Composer struct {
+	resolve  MentionResolver
+	maxBytes int
+}
+
+// NewComposer returns a composer that resolves mentions with the given
+// resolver. Mentions are only syntax checked if resolver is nil.
+func NewComposer(resolver MentionResolver) *Composer {
+	return &Composer{
+		resolve:  resolver,
+		maxBytes: MaxCastBytes,
+	}
+}
+
+// Compose validates the text and extracts its mentions and links. The returned
+// error is a ValidationErrors if the text can't be published as is.
+func (c *Composer) Compose(text string) (*Draft, error) {
+	var errs ValidationErrors
+	if !utf8.ValidString(text) {
+		errs = append(errs, &ValidationError{Kind: ErrInvalidUTF8, Offset: invalidUTF8Offset(text), Message: "text is not valid UTF-8"})
+	}
+	if strings.TrimSpace(text) == "" {
+		errs = append(errs, &ValidationError{Kind: ErrEmptyText, Message: "text is empty"})
+	}
+	if len(text) > c.maxBytes {
+		errs = append(errs, &ValidationError{
+			Kind:    ErrTextTooLong,
+			Offset:  c.maxBytes,
+			Message: fmt.Sprintf("text is %d bytes, limit is %d", len(text), c.maxBytes),
+		})
+	}
+
+	draft := &Draft{Text: text}
+	resolved := make(map[string]uint64)
+	for _, index := range users.MentionIndexes(text) {
+		offset := index[0] - 1
+		username := text[index[0]:index[1]]
+		if !users.ValidUsername(username) {
+			errs = append(errs, &ValidationError{
+				Kind:    ErrInvalidMention,
+				Offset:  offset,
+				Message: fmt.Sprintf("@%s is not a valid username", username),
+			})
+			continue
+		}
+		fid, ok := resolved[username]
+		if !ok && c.resolve != nil {
+			var err error
+			fid, err = c.resolve(username)
+			if err != nil {
+				errs = append(errs, &ValidationError{
+					Kind:    ErrUnresolvedMention,
+					Offset:  offset,
+					Message: fmt.Sprintf("@%s: %s", username, err),
+				})
+				continue
+			}
+			resolved[username] = fid
+		}
+		draft.Mentions = append(draft.Mentions, Mention{Username: username, Fid: fid, Offset: offset})
+	}
+
+	embeds := 0
+	for _, match := range linkRegexp.FindAllStringIndex(text, -1) {
+		raw := users.TrimLink(text[match[0]:match[1]])
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Host == "" {
+			errs = append(errs, &ValidationError{
+				Kind:    ErrInvalidLink,
+				Offset:  match[0],
+				Message: fmt.Sprintf("%s is not a valid URL", raw),
+			})
+			continue
+		}
+		link := Link{Url: raw, Offset: match[0], Embed: isEmbed(parsed)}
+		if link.Embed {
+			embeds++
+			if embeds > MaxEmbeds {
+				errs = append(errs, &ValidationError{
+					Kind:    ErrTooManyEmbeds,
+					Offset:  match[0],
+					Message: fmt.Sprintf("only %d embeds are allowed", MaxEmbeds),
+				})
+			}
+		}
+		draft.Links = append(draft.Links, link)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return draft, nil
+}
+
+func isEmbed(u *url.URL) bool {
+	if embedExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+	// Links to other casts are rendered as quote embeds.
+	host := strings.TrimPrefix(u.Host, "www.")
+	return host == "warpcast.com" && strings.Count(strings.Trim(u.Path, "/"), "/") == 1
+}
+
+func invalidUTF8Offset(text string) int {
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return 0
+}
+
+// PublishDraft publishes the text of a draft along with its embeds.
+func (c *CastService) PublishDraft(draft *Draft) (*Cast, error) {
+	return c.publishDraft(draft, 0, "")
+}
+
+// PublishReplyDraft publishes a draft as a reply to the cast hash by fid.
+func (c *CastService) PublishReplyDraft(draft *Draft, fid uint64, hash string) (*Cast, error) {
+	if hash == "" {
+		return nil, errors.New("parent hash is empty")
+	}
+	return c.publishDraft(draft, fid, hash)
+}
+
+func (c *CastService) publishDraft(draft *Draft, fid uint64, hash string) (*Cast, error) {
+	if draft == nil {
+		return nil, errors.New("draft is nil")
+	}
+	type Parent struct {
+		Fid  uint64 `json:"fid"`
+		Hash string `json:"hash"`
+	}
+	type PublishCastRequest struct {
+		Text   string   `json:"text"`
+		Embeds []string `json:"embeds,omitempty"`
+		Parent *Parent  `json:"parent,omitempty"`
+	}
+	request := PublishCastRequest{
+		Text:   draft.Text,
+		Embeds: draft.Embeds(),
+	}
+	if hash != "" {
+		request.Parent = &Parent{
+			Fid:  fid,
+			Hash: hash,
+		}
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	cast, err := c.publishCast(requestBytes)
+	if err == nil && hash != "" {
+		// The reply count of the parent changed.
+		c.cache.Delete(cache.KindCast, hash)
+	}
+	return cast, err
+}
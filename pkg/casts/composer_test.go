@@ -0,0 +1,90 @@
+package casts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestComposerCompose(t *testing.T) {
+	composer := NewComposer(func(username string) (uint64, error) {
+		if username == "dwr" {
+			return 3, nil
+		}
+		return 0, errors.New("fname not found")
+	})
+	draft, err := composer.Compose("gm @dwr, see https://example.com/a.png and https://example.com/post.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(draft.Mentions) != 1 || draft.Mentions[0].Fid != 3 || draft.Mentions[0].Offset != 3 {
+		t.Errorf("Expected @dwr resolved to fid 3 at offset 3, got %+v", draft.Mentions)
+	}
+	if len(draft.Links) != 2 || draft.Links[1].Url != "https://example.com/post" {
+		t.Errorf("Expected two links with trailing punctuation trimmed, got %+v", draft.Links)
+	}
+	if embeds := draft.Embeds(); len(embeds) != 1 || embeds[0] != "https://example.com/a.png" {
+		t.Errorf("Expected the image to be an embed, got %v", embeds)
+	}
+	if draft, _ := composer.Compose("mail me at me@example.com"); draft == nil || len(draft.Mentions) != 0 {
+		t.Errorf("Expected email addresses not to be mentions")
+	}
+	if draft, err := composer.Compose("thanks @dwr_!"); err != nil || len(draft.Mentions) != 1 || draft.Mentions[0].Username != "dwr" {
+		t.Errorf("Expected a mention to end where the username does, got %+v, %v", draft, err)
+	}
+}
+
+func TestPublishDraft(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		w.Write([]byte(`{"result":{"cast":{"hash":"0x1"}}}`))
+	}))
+	defer server.Close()
+	castService := NewCastService(account.NewAccountService(server.URL, ""), nil)
+
+	draft, err := NewComposer(nil).Compose("look https://example.com/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := castService.PublishDraft(draft); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := castService.PublishReplyDraft(draft, 3, "0xp"); err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range requests {
+		if embeds, _ := request["embeds"].([]interface{}); len(embeds) != 1 || embeds[0] != "https://example.com/a.png" {
+			t.Errorf("Expected the image to be sent as an embed, got %v", request)
+		}
+	}
+	if parent, _ := requests[1]["parent"].(map[string]interface{}); parent["hash"] != "0xp" {
+		t.Errorf("Expected the reply to have a parent, got %v", requests[1])
+	}
+}
+
+func TestComposerValidationErrors(t *testing.T) {
+	composer := NewComposer(func(username string) (uint64, error) {
+		return 0, errors.New("fname not found")
+	})
+	_, err := composer.Compose("@nobody @Upper " + strings.Repeat("a", MaxCastBytes))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	for _, kind := range []ValidationErrorKind{ErrTextTooLong, ErrUnresolvedMention, ErrInvalidMention} {
+		if !errs.Has(kind) {
+			t.Errorf("Expected %s in %v", kind, errs)
+		}
+	}
+	if _, err := composer.Compose("   "); err == nil || !err.(ValidationErrors).Has(ErrEmptyText) {
+		t.Errorf("Expected empty text error, got %v", err)
+	}
+}
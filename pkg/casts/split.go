@@ -0,0 +1,195 @@
+package casts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var sentenceEndRegexp = regexp.MustCompile(`[.!?…]+["')\]]*\s+`)
+
+type ThreadOptions struct {
+	// MaxBytes is the limit for each cast, MaxCastBytes if zero.
+	MaxBytes int
+	// Numbered appends " (i/n)" to every cast of a multi cast thread.
+	Numbered bool
+	// Rollback deletes the already published casts if a step fails.
+	Rollback bool
+}
+
+// PublishThreadError is returned when a thread is only partially published.
+type PublishThreadError struct {
+	// Step is the index of the part that failed.
+	Step int
+	// Published holds the casts that were published before the failure. It is
+	// empty if they were all rolled back.
+	Published   []*Cast
+	Err         error
+	RolledBack  bool
+	RollbackErr error
+}
+
+func (e *PublishThreadError) Error() string {
+	msg := fmt.Sprintf("publishing part %d of thread: %s", e.Step+1, e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %s)", e.RollbackErr)
+	}
+	return msg
+}
+
+func (e *PublishThreadError) Unwrap() error {
+	return e.Err
+}
+
+// SplitThread splits text into parts that fit in maxBytes, preferring sentence
+// boundaries, then word boundaries. If numbered is set and there is more than
+// one part, each part gets an " (i/n)" suffix that counts towards the limit.
+func SplitThread(text string, maxBytes int, numbered bool) ([]string, error) {
+	if maxBytes <= 0 {
+		maxBytes = MaxCastBytes
+	}
+	// Words are cut at rune boundaries, so every part must fit the widest rune.
+	if maxBytes < utf8.UTFMax {
+		return nil, fmt.Errorf("limit of %d bytes is smaller than a rune", maxBytes)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("text is empty")
+	}
+	parts := splitText(text, maxBytes)
+	if !numbered || len(parts) == 1 {
+		return parts, nil
+	}
+	// The suffix length depends on the number of parts, so split again with
+	// room for the suffix of width parts. Fewer parts get shorter suffixes,
+	// only more parts need another split, so width only grows.
+	for width := len(parts); ; width = len(parts) {
+		budget := maxBytes - len(numberSuffix(width, width))
+		if budget < utf8.UTFMax {
+			return nil, fmt.Errorf("limit of %d bytes is too small to number %d parts", maxBytes, width)
+		}
+		parts = splitText(text, budget)
+		if len(parts) <= width {
+			break
+		}
+	}
+	for i := range parts {
+		parts[i] += numberSuffix(i+1, len(parts))
+	}
+	return parts, nil
+}
+
+func numberSuffix(i, total int) string {
+	return fmt.Sprintf(" (%d/%d)", i, total)
+}
+
+func splitText(text string, maxBytes int) []string {
+	var parts []string
+	current := ""
+	flush := func() {
+		if current != "" {
+			parts = append(parts, current)
+			current = ""
+		}
+	}
+	add := func(chunk string) {
+		if current == "" {
+			current = chunk
+		} else if len(current)+1+len(chunk) <= maxBytes {
+			current += " " + chunk
+		} else {
+			flush()
+			current = chunk
+		}
+	}
+	for _, sentence := range splitSentences(text) {
+		if len(sentence) <= maxBytes {
+			add(sentence)
+			continue
+		}
+		for _, word := range strings.Fields(sentence) {
+			for len(word) > maxBytes {
+				cut := runeBoundary(word, maxBytes)
+				flush()
+				parts = append(parts, word[:cut])
+				word = word[cut:]
+			}
+			add(word)
+		}
+	}
+	flush()
+	return parts
+}
+
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, match := range sentenceEndRegexp.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[start:match[1]]))
+		start = match[1]
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// runeBoundary returns the largest index <= max that doesn't cut a rune.
+func runeBoundary(s string, max int) int {
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return max
+}
+
+// PublishThread splits text with SplitThread and publishes it as a chain where
+// every part replies to the previous one. The published casts are returned in
+// order. If a step fails, a *PublishThreadError describes how far it got.
+func (c *CastService) PublishThread(text string, options *ThreadOptions) ([]*Cast, error) {
+	if options == nil {
+		options = &ThreadOptions{}
+	}
+	parts, err := SplitThread(text, options.MaxBytes, options.Numbered)
+	if err != nil {
+		return nil, err
+	}
+	published := make([]*Cast, 0, len(parts))
+	for i, part := range parts {
+		var cast *Cast
+		if i == 0 {
+			cast, err = c.PublishCast(part)
+		} else {
+			previous := published[i-1]
+			if previous.Author == nil {
+				err = errors.New("previous cast has no author")
+			} else {
+				cast, err = c.PublishReplyCast(part, previous.Author.Fid, previous.Hash)
+			}
+		}
+		if err == nil && cast == nil {
+			err = errors.New("empty publish response")
+		}
+		if err != nil {
+			threadErr := &PublishThreadError{Step: i, Published: published, Err: err}
+			if options.Rollback && len(published) > 0 {
+				threadErr.Published, threadErr.RollbackErr = c.deleteCasts(published)
+				threadErr.RolledBack = threadErr.RollbackErr == nil
+			}
+			return nil, threadErr
+		}
+		published = append(published, cast)
+	}
+	return published, nil
+}
+
+// deleteCasts deletes casts newest first and returns the ones left behind.
+func (c *CastService) deleteCasts(casts []*Cast) ([]*Cast, error) {
+	for i := len(casts) - 1; i >= 0; i-- {
+		if err := c.DeleteCast(casts[i].Hash); err != nil {
+			return casts[:i+1], err
+		}
+	}
+	return nil, nil
+}
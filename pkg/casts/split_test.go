@@ -0,0 +1,119 @@
+package casts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestSplitThread(t *testing.T) {
+	text := "First sentence is here. Second one follows! And a third?"
+	parts, err := SplitThread(text, 30, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"First sentence is here.", "Second one follows!", "And a third?"}
+	if strings.Join(parts, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, parts)
+	}
+	parts, err = SplitThread(strings.Repeat("word ", 100), 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, part := range parts {
+		if len(part) > 50 {
+			t.Errorf("Part %d is %d bytes", i, len(part))
+		}
+		if !strings.HasSuffix(part, fmt.Sprintf("(%d/%d)", i+1, len(parts))) {
+			t.Errorf("Part %d is not numbered: %q", i, part)
+		}
+	}
+	if parts, _ := SplitThread("short", 0, true); len(parts) != 1 || parts[0] != "short" {
+		t.Errorf("Expected a single unnumbered part, got %q", parts)
+	}
+}
+
+func TestSplitThreadMultibyte(t *testing.T) {
+	if _, err := SplitThread("😀😀", 3, false); err == nil {
+		t.Errorf("Expected a limit below a rune to fail")
+	}
+	parts, err := SplitThread("😀😀😀", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(parts, "|") != "😀|😀|😀" {
+		t.Errorf("Expected one emoji per part, got %q", parts)
+	}
+	if _, err := SplitThread(strings.Repeat("😀", 20), 9, true); err == nil {
+		t.Errorf("Expected a numbered limit below a rune to fail")
+	}
+}
+
+func TestSplitThreadNumberedTerminates(t *testing.T) {
+	// Sentence first splitting isn't monotonic in the budget, so the number
+	// of parts can flip between two counts as the suffix grows.
+	random := rand.New(rand.NewSource(1))
+	words := []string{"a", "gm", "ship", "farcaster", "protocol.", "why?", "now!", "longerword"}
+	for i := 0; i < 2000; i++ {
+		var b strings.Builder
+		for n := random.Intn(60) + 1; n > 0; n-- {
+			b.WriteString(words[random.Intn(len(words))] + " ")
+		}
+		maxBytes := random.Intn(40) + 15
+		parts, err := SplitThread(b.String(), maxBytes, true)
+		if err != nil {
+			continue
+		}
+		for j, part := range parts {
+			if len(part) > maxBytes {
+				t.Fatalf("Part %d of %q is %d bytes, limit %d", j, b.String(), len(part), maxBytes)
+			}
+			if len(parts) > 1 && !strings.HasSuffix(part, fmt.Sprintf("(%d/%d)", j+1, len(parts))) {
+				t.Fatalf("Part %d of %q is not numbered: %q", j, b.String(), part)
+			}
+		}
+	}
+}
+
+func TestPublishThreadRollback(t *testing.T) {
+	published, deleted := 0, []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			published++
+			if published == 3 {
+				w.Write([]byte(`{"errors":[{"message":"rate limited"}]}`))
+				return
+			}
+			w.Write([]byte(fmt.Sprintf(`{"result":{"cast":{"hash":"0x%d","author":{"fid":7}}}}`, published)))
+		case "DELETE":
+			var request struct {
+				CastHash string `json:"castHash"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			deleted = append(deleted, request.CastHash)
+			w.Write([]byte(`{"result":{"success":true}}`))
+		}
+	}))
+	defer server.Close()
+
+	service := NewCastService(account.NewAccountService(server.URL, ""), nil)
+	_, err := service.PublishThread("One. Two. Three.", &ThreadOptions{MaxBytes: 5, Rollback: true})
+	var threadErr *PublishThreadError
+	if !errors.As(err, &threadErr) {
+		t.Fatalf("Expected PublishThreadError, got %v", err)
+	}
+	if threadErr.Step != 2 || !threadErr.RolledBack || len(threadErr.Published) != 0 {
+		t.Errorf("Expected failure at step 2 with rollback, got %+v", threadErr)
+	}
+	if strings.Join(deleted, ",") != "0x2,0x1" {
+		t.Errorf("Expected casts deleted newest first, got %v", deleted)
+	}
+}
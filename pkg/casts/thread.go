@@ -0,0 +1,283 @@
+package casts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ThreadNode is a single cast in a reconstructed reply tree.
+type ThreadNode struct {
+	Cast     *Cast
+	Parent   *ThreadNode
+	Children []*ThreadNode
+	Depth    int
+	// Orphan is set when the cast replies to a parent that isn't part of the
+	// thread anymore, usually because the parent was deleted, or when its
+	// parents loop back to it.
+	Orphan bool
+}
+
+// Thread is the reply tree built from the flat list returned by GetCastsInThread.
+type Thread struct {
+	Hash    string
+	Root    *ThreadNode
+	Orphans []*ThreadNode
+	nodes   map[string]*ThreadNode
+}
+
+// NewThread builds a reply tree out of the casts of a single thread. Children
+// are ordered by timestamp. Casts whose parent is missing are collected in
+// Orphans instead of being dropped, and so is one cast of every parent cycle
+// so that Walk reaches every cast.
+func NewThread(casts []Cast) (*Thread, error) {
+	if len(casts) == 0 {
+		return nil, errors.New("no casts in thread")
+	}
+	thread := &Thread{
+		Hash:  casts[0].ThreadHash,
+		nodes: make(map[string]*ThreadNode, len(casts)),
+	}
+	for i := range casts {
+		cast := &casts[i]
+		if cast.ThreadHash != thread.Hash {
+			return nil, fmt.Errorf("cast %s belongs to thread %s, not %s", cast.Hash, cast.ThreadHash, thread.Hash)
+		}
+		if _, ok := thread.nodes[cast.Hash]; ok {
+			continue
+		}
+		thread.nodes[cast.Hash] = &ThreadNode{Cast: cast}
+	}
+	for _, node := range thread.nodes {
+		cast := node.Cast
+		if cast.ParentHash == "" || cast.Hash == thread.Hash {
+			if thread.Root != nil {
+				return nil, fmt.Errorf("thread %s has more than one root", thread.Hash)
+			}
+			thread.Root = node
+			continue
+		}
+		parent, ok := thread.nodes[cast.ParentHash]
+		if !ok {
+			node.Orphan = true
+			thread.Orphans = append(thread.Orphans, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+	thread.breakCycles()
+	for _, node := range thread.nodes {
+		sortNodes(node.Children)
+	}
+	sortNodes(thread.Orphans)
+	sort.SliceStable(thread.Orphans, func(i, j int) bool {
+		return thread.Orphans[i].Cast.ParentHash < thread.Orphans[j].Cast.ParentHash
+	})
+	if thread.Root != nil {
+		setDepth(thread.Root, 0)
+	}
+	// Orphans hang below a parent that is gone, so they start one level deep.
+	for _, orphan := range thread.Orphans {
+		setDepth(orphan, 1)
+	}
+	return thread, nil
+}
+
+// breakCycles detaches the earliest cast of every parent cycle, a self reply
+// included, from its parent and makes it an orphan.
+func (t *Thread) breakCycles() {
+	reached := make(map[*ThreadNode]bool, len(t.nodes))
+	mark := func(node *ThreadNode) bool {
+		if reached[node] {
+			return false
+		}
+		reached[node] = true
+		return true
+	}
+	if t.Root != nil {
+		t.Root.Walk(mark)
+	}
+	for _, orphan := range t.Orphans {
+		orphan.Walk(mark)
+	}
+	var unreached []*ThreadNode
+	for _, node := range t.nodes {
+		if !reached[node] {
+			unreached = append(unreached, node)
+		}
+	}
+	sortNodes(unreached)
+	for _, node := range unreached {
+		if reached[node] {
+			continue
+		}
+		// Every unreached cast is in a cycle or below one, so following the
+		// parents leads into a cycle.
+		seen := make(map[*ThreadNode]bool)
+		for !seen[node] {
+			seen[node] = true
+			node = node.Parent
+		}
+		first := node
+		for n := node.Parent; n != node; n = n.Parent {
+			if n.Cast.Timestamp < first.Cast.Timestamp || (n.Cast.Timestamp == first.Cast.Timestamp && n.Cast.Hash < first.Cast.Hash) {
+				first = n
+			}
+		}
+		siblings := first.Parent.Children
+		for i, child := range siblings {
+			if child == first {
+				first.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+				break
+			}
+		}
+		first.Parent = nil
+		first.Orphan = true
+		t.Orphans = append(t.Orphans, first)
+		first.Walk(mark)
+	}
+}
+
+func sortNodes(nodes []*ThreadNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Cast.Timestamp != nodes[j].Cast.Timestamp {
+			return nodes[i].Cast.Timestamp < nodes[j].Cast.Timestamp
+		}
+		return nodes[i].Cast.Hash < nodes[j].Cast.Hash
+	})
+}
+
+func setDepth(node *ThreadNode, depth int) {
+	node.Depth = depth
+	for _, child := range node.Children {
+		setDepth(child, depth+1)
+	}
+}
+
+func (c *CastService) GetThread(threadHash string) (*Thread, error) {
+	casts, err := c.GetCastsInThread(threadHash)
+	if err != nil {
+		return nil, err
+	}
+	return NewThread(casts)
+}
+
+// Len returns the number of casts in the thread, orphans included.
+func (t *Thread) Len() int {
+	return len(t.nodes)
+}
+
+// Node returns the node for the given cast hash or nil if it isn't in the thread.
+func (t *Thread) Node(hash string) *ThreadNode {
+	return t.nodes[hash]
+}
+
+// Walk visits the root subtree and then every orphan subtree depth-first in
+// reply order. Returning false from fn skips the children of that node.
+func (t *Thread) Walk(fn func(node *ThreadNode) bool) {
+	if t.Root != nil {
+		t.Root.Walk(fn)
+	}
+	for _, orphan := range t.Orphans {
+		orphan.Walk(fn)
+	}
+}
+
+// Casts returns the casts of the thread in Walk order.
+func (t *Thread) Casts() []*Cast {
+	casts := make([]*Cast, 0, len(t.nodes))
+	t.Walk(func(node *ThreadNode) bool {
+		casts = append(casts, node.Cast)
+		return true
+	})
+	return casts
+}
+
+// MaxDepth returns the depth of the deepest reply in the thread.
+func (t *Thread) MaxDepth() int {
+	max := 0
+	t.Walk(func(node *ThreadNode) bool {
+		if node.Depth > max {
+			max = node.Depth
+		}
+		return true
+	})
+	return max
+}
+
+func (n *ThreadNode) Walk(fn func(node *ThreadNode) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Ancestors returns the chain of parents from the direct parent up to the root.
+func (n *ThreadNode) Ancestors() []*ThreadNode {
+	var ancestors []*ThreadNode
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// Text renders the thread as an indented plain text conversation.
+func (t *Thread) Text() string {
+	var sb strings.Builder
+	t.render(&sb, func(sb *strings.Builder, depth int, author, text string) {
+		indent := strings.Repeat("  ", depth)
+		lines := strings.Split(text, "\n")
+		fmt.Fprintf(sb, "%s\n", strings.TrimRight(fmt.Sprintf("%s%s: %s", indent, author, lines[0]), " "))
+		for _, line := range lines[1:] {
+			fmt.Fprintf(sb, "%s  %s\n", indent, line)
+		}
+	})
+	return sb.String()
+}
+
+// Markdown renders the thread as nested Markdown lists.
+func (t *Thread) Markdown() string {
+	var sb strings.Builder
+	t.render(&sb, func(sb *strings.Builder, depth int, author, text string) {
+		indent := strings.Repeat("  ", depth)
+		lines := strings.Split(text, "\n")
+		fmt.Fprintf(sb, "%s\n", strings.TrimRight(fmt.Sprintf("%s- **%s**: %s", indent, author, lines[0]), " "))
+		for _, line := range lines[1:] {
+			fmt.Fprintf(sb, "%s  %s\n", indent, line)
+		}
+	})
+	return sb.String()
+}
+
+func (t *Thread) render(sb *strings.Builder, line func(sb *strings.Builder, depth int, author, text string)) {
+	write := func(node *ThreadNode) bool {
+		line(sb, node.Depth, authorName(node.Cast), node.Cast.Text)
+		return true
+	}
+	if t.Root != nil {
+		t.Root.Walk(write)
+	}
+	// Orphans that share a deleted parent are grouped under one placeholder.
+	parent := ""
+	for _, orphan := range t.Orphans {
+		if orphan.Cast.ParentHash != parent {
+			parent = orphan.Cast.ParentHash
+			line(sb, 0, "[deleted]", "")
+		}
+		orphan.Walk(write)
+	}
+}
+
+func authorName(cast *Cast) string {
+	if cast.Author == nil {
+		return "unknown"
+	}
+	if cast.Author.Username != "" {
+		return "@" + cast.Author.Username
+	}
+	return fmt.Sprintf("fid:%d", cast.Author.Fid)
+}
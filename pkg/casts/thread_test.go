@@ -0,0 +1,91 @@
+package casts
+
+import (
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func testCast(hash, parent, username string, timestamp uint64, text string) Cast {
+	return Cast{
+		Hash:       hash,
+		ThreadHash: "0xroot",
+		ParentHash: parent,
+		Author:     &users.User{Username: username},
+		Text:       text,
+		Timestamp:  timestamp,
+	}
+}
+
+func TestNewThread(t *testing.T) {
+	thread, err := NewThread([]Cast{
+		testCast("0xc", "0xa", "carol", 3, "second reply"),
+		testCast("0xroot", "", "alice", 1, "hello"),
+		testCast("0xb", "0xroot", "bob", 2, "hi alice"),
+		testCast("0xa", "0xroot", "dan", 1, "first"),
+		testCast("0xd", "0xgone", "erin", 5, "reply to deleted"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.Root == nil || thread.Root.Cast.Hash != "0xroot" {
+		t.Fatalf("Expected root 0xroot, got %v", thread.Root)
+	}
+	if thread.Len() != 5 {
+		t.Errorf("Expected 5 casts, got %d", thread.Len())
+	}
+	if len(thread.Root.Children) != 2 || thread.Root.Children[0].Cast.Hash != "0xa" {
+		t.Errorf("Expected children ordered by timestamp, got %v", thread.Root.Children)
+	}
+	if node := thread.Node("0xc"); node == nil || node.Depth != 2 || len(node.Ancestors()) != 2 {
+		t.Errorf("Expected 0xc at depth 2, got %v", node)
+	}
+	if len(thread.Orphans) != 1 || !thread.Orphans[0].Orphan {
+		t.Errorf("Expected one orphan, got %v", thread.Orphans)
+	}
+	if thread.MaxDepth() != 2 {
+		t.Errorf("Expected max depth 2, got %d", thread.MaxDepth())
+	}
+	expected := "@alice: hello\n" +
+		"  @dan: first\n" +
+		"    @carol: second reply\n" +
+		"  @bob: hi alice\n" +
+		"[deleted]:\n" +
+		"  @erin: reply to deleted\n"
+	if text := thread.Text(); text != expected {
+		t.Errorf("Expected text:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestNewThreadCycles(t *testing.T) {
+	thread, err := NewThread([]Cast{
+		testCast("0xroot", "", "alice", 1, "hello"),
+		testCast("0xself", "0xself", "bob", 2, "me"),
+		testCast("0xa", "0xb", "carol", 3, "a"),
+		testCast("0xb", "0xa", "dan", 4, "b"),
+		testCast("0xc", "0xb", "erin", 5, "c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(thread.Casts()); n != thread.Len() {
+		t.Fatalf("Expected Walk to reach all %d casts, got %d", thread.Len(), n)
+	}
+	if len(thread.Orphans) != 2 || thread.Orphans[0].Cast.Hash != "0xa" || thread.Orphans[1].Cast.Hash != "0xself" {
+		t.Errorf("Expected the earliest cast of each cycle as orphans, got %v", thread.Orphans)
+	}
+	if node := thread.Node("0xc"); node.Depth != 3 {
+		t.Errorf("Expected 0xc below the broken cycle at depth 3, got %d", node.Depth)
+	}
+}
+
+func TestNewThreadErrors(t *testing.T) {
+	if _, err := NewThread(nil); err == nil {
+		t.Error("Expected error for empty thread")
+	}
+	other := testCast("0xb", "0xroot", "bob", 2, "hi")
+	other.ThreadHash = "0xother"
+	if _, err := NewThread([]Cast{testCast("0xroot", "", "alice", 1, "hello"), other}); err == nil {
+		t.Error("Expected error for mixed threads")
+	}
+}
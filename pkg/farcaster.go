@@ -3,6 +3,7 @@ package farcaster
 import (
 	"github.com/ertan/go-farcaster/pkg/account"
 	"github.com/ertan/go-farcaster/pkg/assets"
+	"github.com/ertan/go-farcaster/pkg/cache"
 	"github.com/ertan/go-farcaster/pkg/casts"
 	"github.com/ertan/go-farcaster/pkg/follows"
 	"github.com/ertan/go-farcaster/pkg/health"
@@ -42,3 +43,12 @@ func NewFarcasterClient(apiUrl, mnemonic, providerWs string) *FarcasterClient {
 		Verifications: verifications.NewVerificationsService(account, registry),
 	}
 }
+
+// SetCache shares c between the services that cache reads or invalidate
+// them after writes. Pass nil to turn caching off.
+func (f *FarcasterClient) SetCache(c *cache.Cache) {
+	f.Casts.SetCache(c)
+	f.Follows.SetCache(c)
+	f.Reactions.SetCache(c)
+	f.Users.SetCache(c)
+}
@@ -0,0 +1,293 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+const (
+	DefaultBaseUrl = "https://warpcast.com"
+	titleLength    = 80
+)
+
+type Options struct {
+	// BaseUrl is used to build the profile and cast permalinks.
+	BaseUrl string
+	// SelfUrl is the URL the feed itself is served from, if known.
+	SelfUrl string
+	// SkipReplies leaves replies out of the feed.
+	SkipReplies bool
+}
+
+// Feed turns a user's casts into RSS 2.0 and Atom 1.0 documents.
+type Feed struct {
+	user    *users.User
+	casts   []casts.Cast
+	options Options
+}
+
+func NewFeed(user *users.User, userCasts []casts.Cast, options *Options) (*Feed, error) {
+	if user == nil {
+		return nil, errors.New("user is nil")
+	}
+	f := &Feed{
+		user:  user,
+		casts: userCasts,
+	}
+	if options != nil {
+		f.options = *options
+	}
+	if f.options.BaseUrl == "" {
+		f.options.BaseUrl = DefaultBaseUrl
+	}
+	f.options.BaseUrl = strings.TrimRight(f.options.BaseUrl, "/")
+	return f, nil
+}
+
+type entry struct {
+	id        string
+	title     string
+	link      string
+	content   string
+	published time.Time
+	// inReplyTo and inReplyToUrl are only set for replies.
+	inReplyTo    string
+	inReplyToUrl string
+}
+
+func (f *Feed) entries() []entry {
+	var entries []entry
+	for _, cast := range f.casts {
+		if cast.Recast {
+			continue
+		}
+		reply := cast.ParentHash != ""
+		if reply && f.options.SkipReplies {
+			continue
+		}
+		e := entry{
+			id:        "farcaster:cast:" + cast.Hash,
+			title:     castTitle(cast.Text),
+			link:      f.castUrl(cast),
+			content:   cast.Text,
+			published: time.UnixMilli(int64(cast.Timestamp)).UTC(),
+		}
+		if reply {
+			parent := "a cast"
+			e.inReplyTo = "farcaster:cast:" + cast.ParentHash
+			if cast.ParentAuthor != nil && cast.ParentAuthor.Username != "" {
+				parent = "@" + cast.ParentAuthor.Username
+				e.inReplyToUrl = fmt.Sprintf("%s/%s/%s", f.options.BaseUrl, cast.ParentAuthor.Username, shortHash(cast.ParentHash))
+			}
+			e.title = fmt.Sprintf("Reply to %s: %s", parent, e.title)
+			e.content = fmt.Sprintf("In reply to %s\n\n%s", parent, cast.Text)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (f *Feed) title() string {
+	if f.user.DisplayName != "" {
+		return fmt.Sprintf("%s (@%s) on Farcaster", f.user.DisplayName, f.user.Username)
+	}
+	return fmt.Sprintf("@%s on Farcaster", f.user.Username)
+}
+
+func (f *Feed) profileUrl() string {
+	return fmt.Sprintf("%s/%s", f.options.BaseUrl, f.user.Username)
+}
+
+func (f *Feed) castUrl(cast casts.Cast) string {
+	username := f.user.Username
+	if cast.Author != nil && cast.Author.Username != "" {
+		username = cast.Author.Username
+	}
+	return fmt.Sprintf("%s/%s/%s", f.options.BaseUrl, username, shortHash(cast.Hash))
+}
+
+// Updated returns the time of the newest cast in the feed.
+func (f *Feed) Updated() time.Time {
+	var updated time.Time
+	for _, e := range f.entries() {
+		if e.published.After(updated) {
+			updated = e.published
+		}
+	}
+	return updated
+}
+
+// Warpcast permalinks use the first 8 hex characters of the hash.
+func shortHash(hash string) string {
+	if len(hash) > 10 {
+		return hash[:10]
+	}
+	return hash
+}
+
+func castTitle(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(title) <= titleLength {
+		return title
+	}
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:titleLength-1])) + "…"
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      *atomLink `xml:"atom:link,omitempty"`
+	Image         *rssImage `xml:"image,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	Url   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (f *Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.title(),
+		Link:        f.profileUrl(),
+		Description: f.user.Profile.Bio.Text,
+	}
+	if channel.Description == "" {
+		channel.Description = f.title()
+	}
+	if updated := f.Updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	if f.options.SelfUrl != "" {
+		channel.AtomLink = &atomLink{Href: f.options.SelfUrl, Rel: "self", Type: "application/rss+xml"}
+	}
+	if f.user.Pfp.Url != "" {
+		channel.Image = &rssImage{Url: f.user.Pfp.Url, Title: channel.Title, Link: channel.Link}
+	}
+	for _, e := range f.entries() {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       e.title,
+			Link:        e.link,
+			Description: e.content,
+			Guid:        rssGuid{Value: e.id},
+			PubDate:     e.published.Format(time.RFC1123Z),
+		})
+	}
+	return marshal(rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Thread  string      `xml:"xmlns:thr,attr"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Icon    string      `xml:"icon,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	Uri  string `xml:"uri"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published"`
+	Link      atomLink       `xml:"link"`
+	Content   atomContent    `xml:"content"`
+	InReplyTo *atomInReplyTo `xml:"thr:in-reply-to,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomInReplyTo struct {
+	Ref  string `xml:"ref,attr"`
+	Href string `xml:"href,attr,omitempty"`
+}
+
+func (f *Feed) Atom() ([]byte, error) {
+	updated := f.Updated()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	feed := atomFeed{
+		Thread:  "http://purl.org/syndication/thread/1.0",
+		Id:      fmt.Sprintf("farcaster:fid:%d", f.user.Fid),
+		Title:   f.title(),
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.user.Username, Uri: f.profileUrl()},
+		Links:   []atomLink{{Href: f.profileUrl(), Rel: "alternate", Type: "text/html"}},
+		Icon:    f.user.Pfp.Url,
+	}
+	if f.options.SelfUrl != "" {
+		feed.Links = append(feed.Links, atomLink{Href: f.options.SelfUrl, Rel: "self", Type: "application/atom+xml"})
+	}
+	for _, e := range f.entries() {
+		entry := atomEntry{
+			Id:        e.id,
+			Title:     e.title,
+			Updated:   e.published.Format(time.RFC3339),
+			Published: e.published.Format(time.RFC3339),
+			Link:      atomLink{Href: e.link, Rel: "alternate", Type: "text/html"},
+			Content:   atomContent{Type: "text", Value: e.content},
+		}
+		if e.inReplyTo != "" {
+			entry.InReplyTo = &atomInReplyTo{Ref: e.inReplyTo, Href: e.inReplyToUrl}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshal(feed)
+}
+
+func marshal(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
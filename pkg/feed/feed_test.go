@@ -0,0 +1,152 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestFeedDocuments(t *testing.T) {
+	user := &users.User{Fid: 1, Username: "alice", DisplayName: "Alice"}
+	userCasts := []casts.Cast{
+		{Hash: "0x1234567890ab", Text: "hello <world>", Timestamp: 1672574400000},
+		{Hash: "0x2234567890ab", Text: "a reply", Timestamp: 1672578000000, ParentHash: "0x99", ParentAuthor: &users.User{Username: "bob"}},
+		{Hash: "0x3234567890ab", Text: "recast", Timestamp: 1672581600000, Recast: true},
+	}
+	f, err := NewFeed(user, userCasts, &Options{SelfUrl: "https://feeds.example/alice.rss"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rss, err := f.RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document rssDocument
+	if err := xml.Unmarshal(rss, &document); err != nil {
+		t.Fatalf("Expected valid RSS, got %v", err)
+	}
+	items := document.Channel.Items
+	if len(items) != 2 || items[0].Link != "https://warpcast.com/alice/0x12345678" || items[0].Description != "hello <world>" {
+		t.Errorf("Expected 2 items without the recast, got %+v", items)
+	}
+	if items[1].Title != "Reply to @bob: a reply" {
+		t.Errorf("Expected the reply to be titled, got %q", items[1].Title)
+	}
+
+	atom, err := f.Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Id        string `xml:"id"`
+			InReplyTo *struct {
+				Ref string `xml:"ref,attr"`
+			} `xml:"http://purl.org/syndication/thread/1.0 in-reply-to"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(atom, &feed); err != nil {
+		t.Fatalf("Expected valid Atom, got %v", err)
+	}
+	if feed.Updated != "2023-01-01T13:00:00Z" || len(feed.Entries) != 2 {
+		t.Errorf("Expected 2 entries updated at the newest cast, got %+v", feed)
+	}
+	if reply := feed.Entries[1].InReplyTo; reply == nil || reply.Ref != "farcaster:cast:0x99" {
+		t.Errorf("Expected the reply to point at its parent, got %+v", reply)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	castList := `{"hash":"0x1","text":"one","timestamp":1672574400000},{"hash":"0x2","text":"two","timestamp":1672574400000}`
+	var castRequests int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/user-by-username":
+			switch r.URL.Query().Get("username") {
+			case "alice":
+			case "broken":
+				fmt.Fprint(w, `{"errors":[{"message":"database exploded"}]}`)
+				return
+			default:
+				fmt.Fprint(w, `{"errors":[{"message":"No FID associated with username"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":{"fid":1,"username":"alice"}}`)
+		case "/v2/casts":
+			atomic.AddInt32(&castRequests, 1)
+			fmt.Fprintf(w, `{"result":{"casts":[%s]}}`, castList)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer api.Close()
+
+	accountService := account.NewAccountService(api.URL, "")
+	handler := NewHandler(users.NewUserService(accountService, nil), casts.NewCastService(accountService, nil), &HandlerOptions{CacheTTL: time.Minute})
+	now := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	handler.clock = func() time.Time { return now }
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", url, nil)
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := get("http://one.example/feeds/alice.rss", "")
+	if response.Code != http.StatusOK || !strings.HasPrefix(response.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatalf("Expected an RSS feed, got %d %s", response.Code, response.Body)
+	}
+	etag := response.Header().Get("ETag")
+	if response := get("http://one.example/feeds/alice.rss", etag); response.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", response.Code)
+	}
+	if response := get("http://one.example/feeds/@alice.atom", ""); response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "<feed") {
+		t.Errorf("Expected an Atom feed, got %d", response.Code)
+	}
+	// Each host gets its own self link.
+	response = get("http://two.example/feeds/alice.rss", "")
+	if !strings.Contains(response.Body.String(), `href="http://two.example/feeds/alice.rss"`) {
+		t.Errorf("Expected the self link of the second host, got %s", response.Body)
+	}
+	if n := atomic.LoadInt32(&castRequests); n != 1 {
+		t.Errorf("Expected the cached casts to be reused for every format and host, got %d cast requests", n)
+	}
+
+	// Deleting a cast changes the ETag once the cache expires.
+	castList = `{"hash":"0x1","text":"one","timestamp":1672574400000}`
+	if response := get("http://one.example/feeds/alice.rss", etag); response.Code != http.StatusNotModified {
+		t.Errorf("Expected the cached feed before expiry, got %d", response.Code)
+	}
+	now = now.Add(2 * time.Minute)
+	response = get("http://one.example/feeds/alice.rss", etag)
+	if response.Code != http.StatusOK || strings.Contains(response.Body.String(), "two") {
+		t.Errorf("Expected a fresh feed without the deleted cast, got %d %s", response.Code, response.Body)
+	}
+
+	if response := get("http://one.example/feeds/nobody.rss", ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", response.Code)
+	}
+	if response := get("http://one.example/feeds/alice.json", ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown format, got %d", response.Code)
+	}
+	if response := get("http://one.example/feeds/alice%26fid=2.rss", ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an invalid username, got %d", response.Code)
+	}
+	if response := get("http://one.example/feeds/broken.rss", ""); response.Code != http.StatusBadGateway || strings.Contains(response.Body.String(), "exploded") {
+		t.Errorf("Expected 502 without the upstream error, got %d %s", response.Code, response.Body)
+	}
+}
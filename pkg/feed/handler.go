@@ -0,0 +1,223 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/cache"
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+type HandlerOptions struct {
+	Options
+	// Limit is the number of casts in a feed. Defaults to 25.
+	Limit int
+	// CacheTTL is how long the casts of a user are served before fetching
+	// them again. Defaults to 5 minutes.
+	CacheTTL time.Duration
+	// CacheSize is the number of users whose casts are cached. Defaults to
+	// 1000.
+	CacheSize int
+	// OnError is called with the upstream errors that are served as 502.
+	OnError func(err error)
+}
+
+// ErrUserNotFound is returned for feeds of unknown users and served as 404.
+var ErrUserNotFound = errors.New("user not found")
+
+const cacheKind cache.Kind = "feed"
+
+// cachedCasts are the profile and casts of a user. Feeds are rendered from
+// them on every request since the self link depends on the request.
+type cachedCasts struct {
+	User    *users.User  `json:"user"`
+	Casts   []casts.Cast `json:"casts"`
+	Expires time.Time    `json:"expires"`
+}
+
+type renderedFeed struct {
+	body    []byte
+	etag    string
+	updated time.Time
+}
+
+// Handler serves feeds at <prefix>/<fid or username>.rss and .atom.
+type Handler struct {
+	users   *users.UserService
+	casts   *casts.CastService
+	options HandlerOptions
+	cache   *cache.Cache
+	clock   func() time.Time
+}
+
+func (h *Handler) now() time.Time {
+	if h.clock == nil {
+		return time.Now()
+	}
+	return h.clock()
+}
+
+func NewHandler(userService *users.UserService, castService *casts.CastService, options *HandlerOptions) *Handler {
+	h := &Handler{
+		users: userService,
+		casts: castService,
+	}
+	if options != nil {
+		h.options = *options
+	}
+	if h.options.Limit <= 0 {
+		h.options.Limit = 25
+	}
+	if h.options.CacheTTL <= 0 {
+		h.options.CacheTTL = 5 * time.Minute
+	}
+	if h.options.CacheSize <= 0 {
+		h.options.CacheSize = 1000
+	}
+	h.cache = cache.New(&cache.Options{
+		Store: cache.NewLRU(h.options.CacheSize),
+		TTLs:  map[cache.Kind]time.Duration{cacheKind: h.options.CacheTTL},
+	})
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Base(r.URL.Path)
+	format := path.Ext(name)
+	user := strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(name, format), "@"))
+	var contentType string
+	switch format {
+	case ".rss":
+		contentType = "application/rss+xml; charset=utf-8"
+	case ".atom":
+		contentType = "application/atom+xml; charset=utf-8"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	// Usernames go into the upstream query unescaped.
+	if _, err := strconv.ParseUint(user, 10, 64); err != nil && !users.ValidUsername(user) {
+		http.NotFound(w, r)
+		return
+	}
+
+	feed, err := h.feed(r, user, format)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, ErrUserNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if h.options.OnError != nil {
+			h.options.OnError(err)
+		}
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.options.CacheTTL.Seconds())))
+	w.Header().Set("ETag", feed.etag)
+	if !feed.updated.IsZero() {
+		w.Header().Set("Last-Modified", feed.updated.UTC().Format(http.TimeFormat))
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && match == w.Header().Get("ETag") {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(feed.body)
+}
+
+func (h *Handler) feed(r *http.Request, user, format string) (renderedFeed, error) {
+	cached, err := h.userCasts(user)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	options := h.options.Options
+	if options.SelfUrl == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		options.SelfUrl = fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+	}
+	f, err := NewFeed(cached.User, cached.Casts, &options)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	var body []byte
+	if format == ".rss" {
+		body, err = f.RSS()
+	} else {
+		body, err = f.Atom()
+	}
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	sum := sha256.Sum256(body)
+	return renderedFeed{
+		body:    body,
+		etag:    fmt.Sprintf(`"%x"`, sum[:16]),
+		updated: f.Updated(),
+	}, nil
+}
+
+// userCasts returns the profile and latest casts of user, cached for
+// CacheTTL.
+func (h *Handler) userCasts(user string) (cachedCasts, error) {
+	var cached cachedCasts
+	if h.cache.Get(cacheKind, user, &cached) && h.now().Before(cached.Expires) {
+		return cached, nil
+	}
+	profile, err := h.user(user)
+	if err != nil {
+		return cachedCasts{}, err
+	}
+	userCasts, _, err := h.casts.GetCastsByFid(profile.Fid, h.options.Limit, "")
+	if err != nil {
+		return cachedCasts{}, err
+	}
+	cached = cachedCasts{
+		User:    profile,
+		Casts:   userCasts,
+		Expires: h.now().Add(h.options.CacheTTL),
+	}
+	h.cache.Set(cacheKind, user, cached)
+	return cached, nil
+}
+
+func (h *Handler) user(user string) (*users.User, error) {
+	var profile *users.User
+	var err error
+	if fid, parseErr := strconv.ParseUint(user, 10, 64); parseErr == nil {
+		profile, err = h.users.GetUserByFid(fid)
+	} else {
+		profile, err = h.users.GetUserByUsername(user)
+	}
+	if err != nil {
+		// The API reports unknown users as errors like "No FID associated
+		// with username x" or "User not found".
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "not found") || strings.Contains(message, "no fid") {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, err)
+		}
+		return nil, err
+	}
+	if profile == nil {
+		return nil, ErrUserNotFound
+	}
+	return profile, nil
+}
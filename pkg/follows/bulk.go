@@ -0,0 +1,214 @@
+package follows
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+type BulkAction string
+
+const (
+	BulkFollow   BulkAction = "follow"
+	BulkUnfollow BulkAction = "unfollow"
+)
+
+type BulkStatus string
+
+const (
+	BulkDone BulkStatus = "done"
+	// BulkSkipped means the account was already (un)followed.
+	BulkSkipped BulkStatus = "skipped"
+	// BulkPlanned is the status of every item that would be acted on in a
+	// dry run.
+	BulkPlanned BulkStatus = "planned"
+	BulkFailed  BulkStatus = "failed"
+)
+
+// BulkResult is the outcome for one target, also written as a JSON line to
+// the log.
+type BulkResult struct {
+	Target   string     `json:"target"`
+	Fid      uint64     `json:"fid,omitempty"`
+	Username string     `json:"username,omitempty"`
+	Action   BulkAction `json:"action"`
+	Status   BulkStatus `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Time     time.Time  `json:"time"`
+}
+
+type BulkOptions struct {
+	// Users resolves usernames without a registry and looks up the viewer
+	// context to skip accounts that are already (un)followed. Without it
+	// targets must be fids or the registry must be set, and nothing is skipped.
+	Users *users.UserService
+	// DryRun resolves and checks every target without following anyone.
+	DryRun bool
+	// Concurrency is the number of targets processed in parallel. Defaults to 4.
+	Concurrency int
+	// Log receives every result as a JSON line as soon as it is known.
+	Log io.Writer
+}
+
+// BulkFollow follows every target, given as a fid or a username with or
+// without "@". Results are returned in the order of targets. Targets that
+// resolve to the same fid are acted on once and share the result.
+func (f *FollowService) BulkFollow(targets []string, options *BulkOptions) ([]BulkResult, error) {
+	return f.bulk(BulkFollow, targets, options)
+}
+
+// BulkUnfollow unfollows every target, see BulkFollow.
+func (f *FollowService) BulkUnfollow(targets []string, options *BulkOptions) ([]BulkResult, error) {
+	return f.bulk(BulkUnfollow, targets, options)
+}
+
+func (f *FollowService) bulk(action BulkAction, targets []string, options *BulkOptions) ([]BulkResult, error) {
+	var opts BulkOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	results := make([]BulkResult, len(targets))
+	var mu sync.Mutex
+	var logErr error
+	log := func(result BulkResult) {
+		if opts.Log == nil {
+			return
+		}
+		line, _ := json.Marshal(result)
+		mu.Lock()
+		if _, err := opts.Log.Write(append(line, '\n')); err != nil && logErr == nil {
+			logErr = err
+		}
+		mu.Unlock()
+	}
+	// claimed maps every fid to the first target that resolved to it, so no
+	// two workers (un)follow the same account.
+	claimed := make(map[uint64]int)
+	duplicateOf := make(map[int]int)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				fid, err := f.resolveTarget(targets[index], opts.Users)
+				if err == nil {
+					mu.Lock()
+					first, ok := claimed[fid]
+					if ok {
+						duplicateOf[index] = first
+					} else {
+						claimed[fid] = index
+					}
+					mu.Unlock()
+					if ok {
+						continue
+					}
+				}
+				result := f.bulkOne(action, targets[index], fid, err, &opts)
+				results[index] = result
+				log(result)
+			}
+		}()
+	}
+	for index := range targets {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	for index := range targets {
+		if first, ok := duplicateOf[index]; ok {
+			results[index] = results[first]
+			results[index].Target = targets[index]
+			log(results[index])
+		}
+	}
+	return results, logErr
+}
+
+// bulkOne acts on the fid target resolved to, resolveErr is the error of
+// resolving it.
+func (f *FollowService) bulkOne(action BulkAction, target string, fid uint64, resolveErr error, opts *BulkOptions) BulkResult {
+	result := BulkResult{
+		Target: target,
+		Action: action,
+	}
+	fail := func(err error) BulkResult {
+		result.Status = BulkFailed
+		result.Error = err.Error()
+		result.Time = time.Now()
+		return result
+	}
+	if resolveErr != nil {
+		return fail(resolveErr)
+	}
+	result.Fid = fid
+	if opts.Users != nil {
+		// A cached user may predate the last (un)follow.
+		user, err := opts.Users.RefreshUserByFid(fid)
+		if err != nil {
+			return fail(err)
+		}
+		if user == nil {
+			return fail(errors.New("user not found"))
+		}
+		result.Username = user.Username
+		if user.ViewerContext != nil && user.ViewerContext.Following == (action == BulkFollow) {
+			result.Status = BulkSkipped
+			result.Time = time.Now()
+			return result
+		}
+	}
+	if opts.DryRun {
+		result.Status = BulkPlanned
+		result.Time = time.Now()
+		return result
+	}
+	var err error
+	if action == BulkFollow {
+		err = f.Follow(fid)
+	} else {
+		err = f.Unfollow(fid)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result.Status = BulkDone
+	result.Time = time.Now()
+	return result
+}
+
+func (f *FollowService) resolveTarget(target string, userService *users.UserService) (uint64, error) {
+	target = strings.TrimSpace(target)
+	if fid, err := strconv.ParseUint(target, 10, 64); err == nil {
+		return fid, nil
+	}
+	username := strings.TrimPrefix(target, "@")
+	if username == "" {
+		return 0, errors.New("empty target")
+	}
+	if f.registry != nil {
+		return f.registry.GetFidByFname(username)
+	}
+	if userService == nil {
+		return 0, errors.New("Registry service is not initialized and no user service is set to resolve usernames.")
+	}
+	user, err := userService.GetUserByUsername(username)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil {
+		return 0, errors.New("user not found")
+	}
+	return user.Fid, nil
+}
@@ -0,0 +1,128 @@
+package follows
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestBulkFollow(t *testing.T) {
+	var mu sync.Mutex
+	followed := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case r.URL.Path == "/v2/user-by-username" && query.Get("username") == "alice":
+			fmt.Fprint(w, `{"result":{"fid":2,"username":"alice"}}`)
+		case r.URL.Path == "/v2/user-by-username":
+			fmt.Fprint(w, `{"errors":[{"message":"No FID associated with username"}]}`)
+		case r.URL.Path == "/v2/user":
+			following := query.Get("fid") == "3"
+			fmt.Fprintf(w, `{"result":{"fid":%s,"username":"u%s","viewerContext":{"following":%t}}}`, query.Get("fid"), query.Get("fid"), following)
+		case r.URL.Path == "/v2/follows" && r.Method == "PUT":
+			var body struct {
+				Fid uint64 `json:"targetFid"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			followed = append(followed, fmt.Sprint(body.Fid))
+			mu.Unlock()
+			fmt.Fprint(w, `{"result":{"success":true}}`)
+		}
+	}))
+	defer server.Close()
+
+	accountService := account.NewAccountService(server.URL, "")
+	service := NewFollowService(accountService, nil)
+	options := &BulkOptions{Users: users.NewUserService(accountService, nil), DryRun: true}
+	targets := []string{"@alice", "3", "bob"}
+
+	results, err := service.BulkFollow(targets, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BulkStatus{BulkPlanned, BulkSkipped, BulkFailed}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("Expected %s to be %s, got %s (%s)", result.Target, want[i], result.Status, result.Error)
+		}
+	}
+	if results[2].Error != "No FID associated with username" {
+		t.Errorf("Expected the API error for bob, got %q", results[2].Error)
+	}
+	if len(followed) != 0 {
+		t.Fatalf("Expected a dry run to follow nobody, followed %v", followed)
+	}
+
+	var log bytes.Buffer
+	options.DryRun = false
+	options.Log = &log
+	if _, err := service.BulkFollow(targets, options); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(followed, ",") != "2" {
+		t.Errorf("Expected only alice to be followed, followed %v", followed)
+	}
+	if lines := strings.Count(log.String(), "\n"); lines != 3 {
+		t.Errorf("Expected 3 log lines, got %d", lines)
+	}
+}
+
+func TestBulkFollowDedupesTargets(t *testing.T) {
+	var mu sync.Mutex
+	following := false
+	follows := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v2/user-by-username":
+			fmt.Fprint(w, `{"result":{"fid":2,"username":"alice"}}`)
+		case r.URL.Path == "/v2/user":
+			fmt.Fprintf(w, `{"result":{"fid":2,"username":"alice","viewerContext":{"following":%t}}}`, following)
+		case r.URL.Path == "/v2/follows" && r.Method == "PUT":
+			follows++
+			following = true
+			fmt.Fprint(w, `{"result":{"success":true}}`)
+		}
+	}))
+	defer server.Close()
+
+	accountService := account.NewAccountService(server.URL, "")
+	service := NewFollowService(accountService, nil)
+	userService := users.NewUserService(accountService, nil)
+	userService.SetCache(cache.New(nil))
+	options := &BulkOptions{Users: userService, Concurrency: 4}
+	targets := []string{"@alice", "2", "alice", "ALICE"}
+
+	results, err := service.BulkFollow(targets, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follows != 1 {
+		t.Errorf("Expected alice to be followed once, got %d follows", follows)
+	}
+	for i, result := range results {
+		if result.Target != targets[i] || result.Fid != 2 || result.Status != BulkDone {
+			t.Errorf("Expected %s to be done for fid 2, got %+v", targets[i], result)
+		}
+	}
+
+	// The cache still has alice unfollowed; the skip check must not use it.
+	results, err = service.BulkFollow([]string{"2"}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follows != 1 || results[0].Status != BulkSkipped {
+		t.Errorf("Expected alice to be skipped, got %s after %d follows", results[0].Status, follows)
+	}
+}
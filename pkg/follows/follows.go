@@ -3,8 +3,10 @@ package follows
 import (
 	"encoding/json"
 	"errors"
+	"sync"
 
 	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
 	"github.com/ertan/go-farcaster/pkg/registry"
 	"github.com/ertan/go-farcaster/pkg/users"
 )
@@ -12,11 +14,16 @@ import (
 type FollowService struct {
 	account  *account.AccountService
 	registry *registry.RegistryService
+	cache    *cache.Cache
+	// viewer is the fid of the account, looked up until it is known to
+	// invalidate its following count.
+	viewerMu sync.Mutex
+	viewer   uint64
 }
 
 type Follow struct {
-	FollowerFid  int64 `json:"follower_fid"`
-	FollowingFid int64 `json:"following_fid"`
+	FollowerFid  users.Fid `json:"follower_fid"`
+	FollowingFid users.Fid `json:"following_fid"`
 }
 
 func NewFollowService(account *account.AccountService, registry *registry.RegistryService) *FollowService {
@@ -26,6 +33,43 @@ func NewFollowService(account *account.AccountService, registry *registry.Regist
 	}
 }
 
+// SetCache sets the cache whose users are invalidated when a follow changes
+// their counts, usually the one of the UserService. Both the target and the
+// viewer are invalidated, the viewer's fid is fetched from /v2/me on the
+// first follow and retried until it succeeds.
+func (f *FollowService) SetCache(c *cache.Cache) {
+	f.cache = c
+}
+
+func (f *FollowService) invalidate(fid uint64) {
+	if f.cache == nil {
+		return
+	}
+	f.cache.Delete(cache.KindUser, cache.Key(fid))
+	if viewer := f.viewerFid(); viewer != 0 {
+		f.cache.Delete(cache.KindUser, cache.Key(viewer))
+	}
+}
+
+// viewerFid returns the fid of the account, or 0 if /v2/me fails. A failed
+// lookup is retried on the next call.
+func (f *FollowService) viewerFid() uint64 {
+	f.viewerMu.Lock()
+	defer f.viewerMu.Unlock()
+	if f.viewer != 0 {
+		return f.viewer
+	}
+	responseBytes, err := f.account.SendRequest("GET", "/v2/me", nil, nil)
+	if err != nil {
+		return 0
+	}
+	var response users.UserResponse
+	if err := json.Unmarshal(responseBytes, &response); err == nil && response.Result != nil {
+		f.viewer = response.Result.Fid
+	}
+	return f.viewer
+}
+
 func (f *FollowService) Follow(fid uint64) error {
 	type FollowRequest struct {
 		Fid uint64 `json:"targetFid"`
@@ -34,6 +78,9 @@ func (f *FollowService) Follow(fid uint64) error {
 		Result struct {
 			Success bool `json:"success"`
 		} `json:"result"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
 	}
 	request := FollowRequest{
 		Fid: fid,
@@ -48,7 +95,12 @@ func (f *FollowService) Follow(fid uint64) error {
 	}
 	var response FollowResponse
 	if err := json.Unmarshal(responseBytes, &response); err == nil {
+		if len(response.Errors) > 0 {
+			// TODO(ertan): Find a better solution to pass the errors here.
+			return errors.New(response.Errors[0].Message)
+		}
 		if response.Result.Success {
+			f.invalidate(fid)
 			return nil
 		}
 	}
@@ -63,6 +115,9 @@ func (f *FollowService) Unfollow(fid uint64) error {
 		Result struct {
 			Success bool `json:"success"`
 		} `json:"result"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
 	}
 	request := UnfollowRequest{
 		Fid: fid,
@@ -77,7 +132,12 @@ func (f *FollowService) Unfollow(fid uint64) error {
 	}
 	var response UnfollowResponse
 	if err := json.Unmarshal(responseBytes, &response); err == nil {
+		if len(response.Errors) > 0 {
+			// TODO(ertan): Find a better solution to pass the errors here.
+			return errors.New(response.Errors[0].Message)
+		}
 		if response.Result.Success {
+			f.invalidate(fid)
 			return nil
 		}
 	}
@@ -0,0 +1,51 @@
+package follows
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestFollowInvalidatesViewer(t *testing.T) {
+	var meRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/me" {
+			// The first lookup fails.
+			if atomic.AddInt32(&meRequests, 1) == 1 {
+				fmt.Fprint(w, `{"errors":[{"message":"unavailable"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":{"fid":1,"username":"me"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"result":{"success":true}}`)
+	}))
+	defer server.Close()
+	service := NewFollowService(account.NewAccountService(server.URL, ""), nil)
+	c := cache.New(nil)
+	service.SetCache(c)
+
+	for i, follow := range []func(uint64) error{service.Follow, service.Unfollow, service.Follow} {
+		c.Set(cache.KindUser, cache.Key(1), users.User{Fid: 1})
+		c.Set(cache.KindUser, cache.Key(2), users.User{Fid: 2})
+		if err := follow(2); err != nil {
+			t.Fatal(err)
+		}
+		var user users.User
+		if c.Get(cache.KindUser, cache.Key(2), &user) {
+			t.Errorf("Expected the target to be invalidated")
+		}
+		if viewerCached := c.Get(cache.KindUser, cache.Key(1), &user); viewerCached != (i == 0) {
+			t.Errorf("Expected the viewer to be invalidated once it is known, got %t after follow %d", viewerCached, i)
+		}
+	}
+	if n := atomic.LoadInt32(&meRequests); n != 2 {
+		t.Errorf("Expected the viewer lookup to be retried once, got %d requests", n)
+	}
+}
@@ -0,0 +1,341 @@
+package follows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot is the full follower and following sets of a fid at a point in
+// time. Both lists are sorted.
+type Snapshot struct {
+	Fid       uint64    `json:"fid"`
+	Taken     time.Time `json:"taken"`
+	Followers []uint64  `json:"followers"`
+	Following []uint64  `json:"following"`
+}
+
+// TakeSnapshot pages through every follower and followed account of fid.
+func (f *FollowService) TakeSnapshot(fid uint64) (*Snapshot, error) {
+	followers, err := f.allFids("/v2/followers", fid)
+	if err != nil {
+		return nil, err
+	}
+	following, err := f.allFids("/v2/following", fid)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Fid:       fid,
+		Taken:     time.Now(),
+		Followers: followers,
+		Following: following,
+	}, nil
+}
+
+func (f *FollowService) allFids(path string, fid uint64) ([]uint64, error) {
+	seen := make(map[uint64]bool)
+	var fids []uint64
+	cursor := ""
+	for {
+		list, next, err := f.getFollows(path, fid, 100, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range list {
+			if !seen[user.Fid] {
+				seen[user.Fid] = true
+				fids = append(fids, user.Fid)
+			}
+		}
+		if next == "" || len(list) == 0 {
+			break
+		}
+		cursor = next
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids, nil
+}
+
+// SnapshotStore keeps snapshots. List returns them oldest first.
+type SnapshotStore interface {
+	Save(snapshot *Snapshot) error
+	List(fid uint64) ([]*Snapshot, error)
+}
+
+// DirSnapshotStore writes every snapshot to <dir>/<fid>/<unix nanos>.json.
+type DirSnapshotStore struct {
+	dir string
+}
+
+func NewDirSnapshotStore(dir string) *DirSnapshotStore {
+	return &DirSnapshotStore{
+		dir: dir,
+	}
+}
+
+func (s *DirSnapshotStore) Save(snapshot *Snapshot) error {
+	dir := filepath.Join(s.dir, strconv.FormatUint(snapshot.Fid, 10))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, strconv.FormatInt(snapshot.Taken.UnixNano(), 10)+".json")
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *DirSnapshotStore) List(fid uint64) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, strconv.FormatUint(fid, 10)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, strconv.FormatUint(fid, 10), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+type MemorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[uint64][]*Snapshot
+}
+
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{
+		snapshots: make(map[uint64][]*Snapshot),
+	}
+}
+
+func (s *MemorySnapshotStore) Save(snapshot *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *snapshot
+	s.snapshots[snapshot.Fid] = append(s.snapshots[snapshot.Fid], &copied)
+	sortSnapshots(s.snapshots[snapshot.Fid])
+	return nil
+}
+
+func (s *MemorySnapshotStore) List(fid uint64) ([]*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots := make([]*Snapshot, len(s.snapshots[fid]))
+	copy(snapshots, s.snapshots[fid])
+	return snapshots, nil
+}
+
+func sortSnapshots(snapshots []*Snapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Taken.Before(snapshots[j].Taken)
+	})
+}
+
+// Diff is what changed for a fid between two snapshots.
+type Diff struct {
+	Fid             uint64    `json:"fid"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	GainedFollowers []uint64  `json:"gainedFollowers"`
+	LostFollowers   []uint64  `json:"lostFollowers"`
+	GainedFollowing []uint64  `json:"gainedFollowing"`
+	LostFollowing   []uint64  `json:"lostFollowing"`
+}
+
+func (d *Diff) Empty() bool {
+	return len(d.GainedFollowers) == 0 && len(d.LostFollowers) == 0 &&
+		len(d.GainedFollowing) == 0 && len(d.LostFollowing) == 0
+}
+
+// DiffSnapshots compares an older snapshot with a newer one of the same fid.
+func DiffSnapshots(older, newer *Snapshot) (*Diff, error) {
+	if older.Fid != newer.Fid {
+		return nil, errors.New("snapshots are of different fids")
+	}
+	diff := &Diff{
+		Fid:  older.Fid,
+		From: older.Taken,
+		To:   newer.Taken,
+	}
+	diff.GainedFollowers, diff.LostFollowers = compareFids(older.Followers, newer.Followers)
+	diff.GainedFollowing, diff.LostFollowing = compareFids(older.Following, newer.Following)
+	return diff, nil
+}
+
+// DiffSince compares the last snapshot taken at or before since with the
+// latest one, e.g. for a weekly report. Without an old enough snapshot the
+// oldest one is used.
+func DiffSince(store SnapshotStore, fid uint64, since time.Time) (*Diff, error) {
+	snapshots, err := store.List(fid)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots of fid %d", fid)
+	}
+	older := snapshots[0]
+	for _, snapshot := range snapshots {
+		if snapshot.Taken.After(since) {
+			break
+		}
+		older = snapshot
+	}
+	return DiffSnapshots(older, snapshots[len(snapshots)-1])
+}
+
+// compareFids expects both lists sorted.
+func compareFids(older, newer []uint64) ([]uint64, []uint64) {
+	var gained, lost []uint64
+	i, j := 0, 0
+	for i < len(older) || j < len(newer) {
+		switch {
+		case j == len(newer) || (i < len(older) && older[i] < newer[j]):
+			lost = append(lost, older[i])
+			i++
+		case i == len(older) || newer[j] < older[i]:
+			gained = append(gained, newer[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return gained, lost
+}
+
+type EventType string
+
+const (
+	// EventFollow and EventUnfollow are other accounts (un)following the fid.
+	EventFollow   EventType = "follow"
+	EventUnfollow EventType = "unfollow"
+	// EventFollowed and EventUnfollowed are the fid (un)following others.
+	EventFollowed   EventType = "followed"
+	EventUnfollowed EventType = "unfollowed"
+)
+
+type FollowEvent struct {
+	Type   EventType
+	Follow Follow
+	// Time is when the change was noticed, not when it happened.
+	Time time.Time
+}
+
+type WatchOptions struct {
+	// Store keeps the snapshots. The latest stored snapshot is the baseline,
+	// so changes made while the watcher was down are reported on start.
+	// Defaults to memory.
+	Store   SnapshotStore
+	OnError func(err error)
+}
+
+// WatchFollows snapshots fid every interval and emits the changes since the
+// previous snapshot. The channel is closed when ctx is done.
+func (f *FollowService) WatchFollows(ctx context.Context, fid uint64, interval time.Duration, options *WatchOptions) (<-chan FollowEvent, error) {
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	var opts WatchOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemorySnapshotStore()
+	}
+	snapshots, err := opts.Store.List(fid)
+	if err != nil {
+		return nil, err
+	}
+	var previous *Snapshot
+	if len(snapshots) > 0 {
+		previous = snapshots[len(snapshots)-1]
+	}
+	out := make(chan FollowEvent)
+	go func() {
+		defer close(out)
+		wait := time.Duration(0)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			wait = interval
+			snapshot, err := f.TakeSnapshot(fid)
+			var diff *Diff
+			if err == nil && previous != nil {
+				diff, err = DiffSnapshots(previous, snapshot)
+			}
+			// Unchanged snapshots aren't stored, the previous one still
+			// describes the account.
+			if err == nil && (diff == nil || !diff.Empty()) {
+				err = opts.Store.Save(snapshot)
+			}
+			if err != nil {
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+				continue
+			}
+			if diff == nil {
+				previous = snapshot
+				continue
+			}
+			for _, event := range diffEvents(diff) {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			previous = snapshot
+		}
+	}()
+	return out, nil
+}
+
+func diffEvents(diff *Diff) []FollowEvent {
+	var events []FollowEvent
+	add := func(eventType EventType, fids []uint64, follower bool) {
+		for _, fid := range fids {
+			follow := Follow{FollowerFid: diff.Fid, FollowingFid: fid}
+			if follower {
+				follow = Follow{FollowerFid: fid, FollowingFid: diff.Fid}
+			}
+			events = append(events, FollowEvent{Type: eventType, Follow: follow, Time: diff.To})
+		}
+	}
+	add(EventFollow, diff.GainedFollowers, true)
+	add(EventUnfollow, diff.LostFollowers, true)
+	add(EventFollowed, diff.GainedFollowing, false)
+	add(EventUnfollowed, diff.LostFollowing, false)
+	return events
+}
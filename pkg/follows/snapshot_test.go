@@ -0,0 +1,119 @@
+package follows
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestDiffSince(t *testing.T) {
+	store := NewDirSnapshotStore(t.TempDir())
+	week := 7 * 24 * time.Hour
+	now := time.Now()
+	for _, snapshot := range []*Snapshot{
+		{Fid: 1, Taken: now.Add(-2 * week), Followers: []uint64{2, 3}, Following: []uint64{2}},
+		{Fid: 1, Taken: now.Add(-week), Followers: []uint64{2, 3, 4}, Following: []uint64{2}},
+		{Fid: 1, Taken: now, Followers: []uint64{3, 4, 5}, Following: []uint64{2, 6}},
+	} {
+		if err := store.Save(snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diff, err := DiffSince(store, 1, now.Add(-week))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.GainedFollowers, []uint64{5}) || !reflect.DeepEqual(diff.LostFollowers, []uint64{2}) {
+		t.Errorf("Expected +5 -2 followers, got +%v -%v", diff.GainedFollowers, diff.LostFollowers)
+	}
+	if !reflect.DeepEqual(diff.GainedFollowing, []uint64{6}) || diff.LostFollowing != nil {
+		t.Errorf("Expected +6 following, got +%v -%v", diff.GainedFollowing, diff.LostFollowing)
+	}
+	if _, err := DiffSince(store, 2, now); err == nil {
+		t.Error("Expected an error without snapshots")
+	}
+}
+
+func TestWatchFollows(t *testing.T) {
+	var mu sync.Mutex
+	followers := []string{`{"fid":2}`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		list := ""
+		if r.URL.Path == "/v2/followers" {
+			list = strings.Join(followers, ",")
+		}
+		fmt.Fprintf(w, `{"result":{"users":[%s]}}`, list)
+	}))
+	defer server.Close()
+
+	service := NewFollowService(account.NewAccountService(server.URL, ""), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := service.WatchFollows(ctx, 1, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	mu.Lock()
+	followers = []string{`{"fid":3}`}
+	mu.Unlock()
+
+	got := map[EventType]uint64{}
+	for len(got) < 2 {
+		select {
+		case event := <-events:
+			got[event.Type] = event.Follow.FollowerFid
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out, got %v", got)
+		}
+	}
+	if got[EventFollow] != 3 || got[EventUnfollow] != 2 {
+		t.Errorf("Expected 3 to follow and 2 to unfollow, got %v", got)
+	}
+}
+
+func TestWatchFollowsSkipsUnchanged(t *testing.T) {
+	requests := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"result":{"users":[{"fid":2}]}}`)
+		if r.URL.Path == "/v2/following" {
+			requests <- struct{}{}
+		}
+	}))
+	defer server.Close()
+
+	service := NewFollowService(account.NewAccountService(server.URL, ""), nil)
+	store := NewMemorySnapshotStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := service.WatchFollows(ctx, 1, time.Millisecond, &WatchOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Six snapshots of an unchanged account.
+	for i := 0; i < 6; i++ {
+		select {
+		case <-requests:
+		case event := <-events:
+			t.Errorf("Expected no events, got %+v", event)
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for snapshots")
+		}
+	}
+	cancel()
+	for range events {
+	}
+	snapshots, _ := store.List(1)
+	if len(snapshots) != 1 {
+		t.Errorf("Expected only the first snapshot to be stored, got %d", len(snapshots))
+	}
+}
@@ -0,0 +1,185 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ertan/go-farcaster/pkg/follows"
+	"github.com/ertan/go-farcaster/pkg/internal/ratelimit"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+type Direction int
+
+const (
+	Both Direction = iota
+	Followers
+	Following
+)
+
+type CrawlOptions struct {
+	// Depth is how many hops from the seeds are crawled. 1 crawls the seeds
+	// only, 2 also crawls every account found there and so on. Defaults to 1.
+	Depth int
+	// Direction selects which lists are fetched. Defaults to Both.
+	Direction Direction
+	// Concurrency is the number of accounts crawled in parallel. Defaults to 4.
+	Concurrency int
+	// RequestsPerSecond caps the API calls across workers. Zero means no limit.
+	RequestsPerSecond float64
+	// PageSize is passed as limit to the follows API.
+	PageSize int
+	// MaxPages caps the pages fetched per list, to keep very large accounts
+	// from dominating the crawl. Zero means no limit.
+	MaxPages int
+	// OnError is called when an account can't be crawled. The crawl goes on.
+	OnError func(fid uint64, err error)
+}
+
+type Crawler struct {
+	follows *follows.FollowService
+	store   Store
+	options CrawlOptions
+}
+
+func NewCrawler(followService *follows.FollowService, store Store, options *CrawlOptions) *Crawler {
+	c := &Crawler{
+		follows: followService,
+		store:   store,
+	}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.Depth <= 0 {
+		c.options.Depth = 1
+	}
+	if c.options.Concurrency <= 0 {
+		c.options.Concurrency = 4
+	}
+	return c
+}
+
+// Crawl walks the follow graph breadth first from seeds and adds every user
+// and edge found to the store. It returns ctx.Err() if the crawl was cut short.
+func (c *Crawler) Crawl(ctx context.Context, seeds ...uint64) error {
+	limit := ratelimit.New(c.options.RequestsPerSecond)
+	defer limit.Stop()
+	visited := make(map[uint64]bool)
+	var frontier []uint64
+	for _, fid := range seeds {
+		if !visited[fid] {
+			visited[fid] = true
+			frontier = append(frontier, fid)
+		}
+	}
+	for depth := 1; depth <= c.options.Depth && len(frontier) > 0; depth++ {
+		found := c.crawlLevel(ctx, limit, frontier)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		frontier = frontier[:0]
+		for _, fid := range found {
+			if !visited[fid] {
+				visited[fid] = true
+				frontier = append(frontier, fid)
+			}
+		}
+	}
+	return nil
+}
+
+// crawlLevel crawls fids in parallel and returns the accounts they link to.
+func (c *Crawler) crawlLevel(ctx context.Context, limit *ratelimit.Limiter, fids []uint64) []uint64 {
+	jobs := make(chan uint64)
+	var mu sync.Mutex
+	var found []uint64
+	var wg sync.WaitGroup
+	for i := 0; i < c.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fid := range jobs {
+				neighbours, err := c.crawlFid(ctx, limit, fid)
+				if err != nil {
+					if ctx.Err() == nil && c.options.OnError != nil {
+						c.options.OnError(fid, err)
+					}
+					continue
+				}
+				mu.Lock()
+				found = append(found, neighbours...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, fid := range fids {
+		select {
+		case jobs <- fid:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return found
+}
+
+func (c *Crawler) crawlFid(ctx context.Context, limit *ratelimit.Limiter, fid uint64) ([]uint64, error) {
+	var neighbours []uint64
+	if c.options.Direction != Following {
+		list, err := c.fetch(ctx, limit, fid, c.follows.GetFollowersByFid)
+		if err != nil {
+			return nil, fmt.Errorf("followers: %w", err)
+		}
+		for i := range list {
+			follower := list[i].Fid
+			if err := c.store.AddUser(&list[i]); err != nil {
+				return nil, err
+			}
+			if err := c.store.AddEdge(follower, fid); err != nil {
+				return nil, err
+			}
+			neighbours = append(neighbours, follower)
+		}
+	}
+	if c.options.Direction != Followers {
+		list, err := c.fetch(ctx, limit, fid, c.follows.GetFollowingByFid)
+		if err != nil {
+			return nil, fmt.Errorf("following: %w", err)
+		}
+		for i := range list {
+			following := list[i].Fid
+			if err := c.store.AddUser(&list[i]); err != nil {
+				return nil, err
+			}
+			if err := c.store.AddEdge(fid, following); err != nil {
+				return nil, err
+			}
+			neighbours = append(neighbours, following)
+		}
+	}
+	return neighbours, nil
+}
+
+func (c *Crawler) fetch(ctx context.Context, limit *ratelimit.Limiter, fid uint64, get func(uint64, int, string) ([]users.User, string, error)) ([]users.User, error) {
+	var all []users.User
+	cursor := ""
+	for page := 0; c.options.MaxPages <= 0 || page < c.options.MaxPages; page++ {
+		if err := limit.Wait(ctx); err != nil {
+			return nil, err
+		}
+		list, next, err := get(fid, c.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
+		if next == "" || len(list) == 0 {
+			break
+		}
+		cursor = next
+	}
+	return all, nil
+}
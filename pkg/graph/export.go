@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+// The exporters write nodes and then edges straight to w, one account at a
+// time, so the output never has to fit in memory. Accounts that were only
+// seen as ids, e.g. the seeds of a crawl, are written without attributes.
+
+// WriteGraphML writes the graph in GraphML, e.g. for Gephi or yEd.
+func WriteGraphML(w io.Writer, store Store) error {
+	out := bufio.NewWriter(w)
+	out.WriteString(xml.Header)
+	out.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	out.WriteString(`  <key id="username" for="node" attr.name="username" attr.type="string"/>` + "\n")
+	out.WriteString(`  <key id="displayName" for="node" attr.name="displayName" attr.type="string"/>` + "\n")
+	out.WriteString(`  <key id="followerCount" for="node" attr.name="followerCount" attr.type="int"/>` + "\n")
+	out.WriteString(`  <key id="followingCount" for="node" attr.name="followingCount" attr.type="int"/>` + "\n")
+	out.WriteString(`  <graph id="follows" edgedefault="directed">` + "\n")
+	err := eachNode(store, func(fid uint64, user *users.User) error {
+		if user == nil {
+			_, err := fmt.Fprintf(out, "    <node id=\"%d\"/>\n", fid)
+			return err
+		}
+		fmt.Fprintf(out, "    <node id=\"%d\">\n", fid)
+		fmt.Fprintf(out, "      <data key=\"username\">%s</data>\n", escapeXML(user.Username))
+		fmt.Fprintf(out, "      <data key=\"displayName\">%s</data>\n", escapeXML(user.DisplayName))
+		fmt.Fprintf(out, "      <data key=\"followerCount\">%d</data>\n", user.FollowerCount)
+		fmt.Fprintf(out, "      <data key=\"followingCount\">%d</data>\n", user.FollowingCount)
+		_, err := out.WriteString("    </node>\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = eachEdge(store, func(id int, follower, following uint64) error {
+		_, err := fmt.Fprintf(out, "    <edge id=\"e%d\" source=\"%d\" target=\"%d\"/>\n", id, follower, following)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteString("  </graph>\n</graphml>\n")
+	return out.Flush()
+}
+
+// WriteGEXF writes the graph in GEXF 1.2, Gephi's native format.
+func WriteGEXF(w io.Writer, store Store) error {
+	out := bufio.NewWriter(w)
+	out.WriteString(xml.Header)
+	out.WriteString(`<gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">` + "\n")
+	out.WriteString(`  <graph mode="static" defaultedgetype="directed">` + "\n")
+	out.WriteString(`    <attributes class="node">` + "\n")
+	out.WriteString(`      <attribute id="0" title="username" type="string"/>` + "\n")
+	out.WriteString(`      <attribute id="1" title="followerCount" type="integer"/>` + "\n")
+	out.WriteString(`      <attribute id="2" title="followingCount" type="integer"/>` + "\n")
+	out.WriteString("    </attributes>\n    <nodes>\n")
+	err := eachNode(store, func(fid uint64, user *users.User) error {
+		if user == nil {
+			_, err := fmt.Fprintf(out, "      <node id=\"%d\" label=\"%d\"/>\n", fid, fid)
+			return err
+		}
+		fmt.Fprintf(out, "      <node id=\"%d\" label=\"%s\">\n", fid, escapeXML(nodeLabel(fid, user)))
+		out.WriteString("        <attvalues>\n")
+		fmt.Fprintf(out, "          <attvalue for=\"0\" value=\"%s\"/>\n", escapeXML(user.Username))
+		fmt.Fprintf(out, "          <attvalue for=\"1\" value=\"%d\"/>\n", user.FollowerCount)
+		fmt.Fprintf(out, "          <attvalue for=\"2\" value=\"%d\"/>\n", user.FollowingCount)
+		_, err := out.WriteString("        </attvalues>\n      </node>\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteString("    </nodes>\n    <edges>\n")
+	err = eachEdge(store, func(id int, follower, following uint64) error {
+		_, err := fmt.Fprintf(out, "      <edge id=\"%d\" source=\"%d\" target=\"%d\"/>\n", id, follower, following)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteString("    </edges>\n  </graph>\n</gexf>\n")
+	return out.Flush()
+}
+
+// WriteDOT writes the graph for Graphviz.
+func WriteDOT(w io.Writer, store Store) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("digraph follows {\n")
+	err := eachNode(store, func(fid uint64, user *users.User) error {
+		if user == nil {
+			_, err := fmt.Fprintf(out, "  %d;\n", fid)
+			return err
+		}
+		_, err := fmt.Fprintf(out, "  %d [label=%s, username=%s, followers=%d, following=%d];\n", fid,
+			quoteDOT(nodeLabel(fid, user)), quoteDOT(user.Username), user.FollowerCount, user.FollowingCount)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = eachEdge(store, func(id int, follower, following uint64) error {
+		_, err := fmt.Fprintf(out, "  %d -> %d;\n", follower, following)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteString("}\n")
+	return out.Flush()
+}
+
+func eachNode(store Store, fn func(fid uint64, user *users.User) error) error {
+	fids, err := store.Fids()
+	if err != nil {
+		return err
+	}
+	for _, fid := range fids {
+		user, err := store.User(fid)
+		if err != nil {
+			return err
+		}
+		if err := fn(fid, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eachEdge(store Store, fn func(id int, follower, following uint64) error) error {
+	fids, err := store.Fids()
+	if err != nil {
+		return err
+	}
+	id := 0
+	for _, follower := range fids {
+		following, err := store.Following(follower)
+		if err != nil {
+			return err
+		}
+		for _, fid := range following {
+			if err := fn(id, follower, fid); err != nil {
+				return err
+			}
+			id++
+		}
+	}
+	return nil
+}
+
+func nodeLabel(fid uint64, user *users.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return fmt.Sprint(fid)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func quoteDOT(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestExporters(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddUser(&users.User{Fid: 1, Username: "a", DisplayName: `A & "B" <c>`, FollowerCount: 10})
+	store.AddEdge(1, 2)
+	store.AddEdge(2, 1)
+
+	for name, write := range map[string]func(io.Writer, Store) error{"graphml": WriteGraphML, "gexf": WriteGEXF} {
+		var b bytes.Buffer
+		if err := write(&b, store); err != nil {
+			t.Fatal(err)
+		}
+		decoder := xml.NewDecoder(&b)
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Expected valid %s, got %v", name, err)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteDOT(&b, store); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, want := range []string{`1 [label="A & \"B\" <c>", username="a", followers=10, following=0];`, "  2;\n", "1 -> 2;", "2 -> 1;"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected DOT to contain %q, got\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/follows"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+// 1 <-> 2, 1 -> 3, 3 -> 4, 2 -> 4, 5 -> 4
+var testEdges = [][2]int{{1, 2}, {2, 1}, {1, 3}, {3, 4}, {2, 4}, {5, 4}}
+
+func testServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fid, _ := strconv.Atoi(r.URL.Query().Get("fid"))
+		var list []string
+		for _, edge := range testEdges {
+			if r.URL.Path == "/v2/followers" && edge[1] == fid {
+				list = append(list, fmt.Sprintf(`{"fid":%d,"username":"u%d"}`, edge[0], edge[0]))
+			}
+			if r.URL.Path == "/v2/following" && edge[0] == fid {
+				list = append(list, fmt.Sprintf(`{"fid":%d,"username":"u%d"}`, edge[1], edge[1]))
+			}
+		}
+		fmt.Fprintf(w, `{"result":{"users":[%s]}}`, strings.Join(list, ","))
+	}))
+}
+
+func TestCrawlAndQuery(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+	followService := follows.NewFollowService(account.NewAccountService(server.URL, ""), nil)
+
+	store := NewMemoryStore()
+	crawler := NewCrawler(followService, store, &CrawlOptions{Depth: 1, Direction: Following})
+	if err := crawler.Crawl(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if fids, _ := store.Fids(); !reflect.DeepEqual(fids, []uint64{1, 2, 3}) {
+		t.Errorf("Expected depth 1 to only reach 1, 2 and 3, got %v", fids)
+	}
+
+	store = NewMemoryStore()
+	crawler = NewCrawler(followService, store, &CrawlOptions{Depth: 3, Concurrency: 2})
+	if err := crawler.Crawl(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGraph(store)
+	if mutuals, _ := g.Mutuals(1); !reflect.DeepEqual(mutuals, []uint64{2}) {
+		t.Errorf("Expected 2 to be the only mutual of 1, got %v", mutuals)
+	}
+	if in, _ := g.InDegree(4); in != 3 {
+		t.Errorf("Expected 4 to have 3 followers, got %d", in)
+	}
+	if out, _ := g.OutDegree(1); out != 2 {
+		t.Errorf("Expected 1 to follow 2 accounts, got %d", out)
+	}
+	if common, _ := g.CommonFollowers(3, 2); !reflect.DeepEqual(common, []uint64{1}) {
+		t.Errorf("Expected 1 to be the common follower of 2 and 3, got %v", common)
+	}
+	if path, _ := g.ShortestPath(3, 2); path != nil {
+		t.Errorf("Expected no path from 3 to 2, got %v", path)
+	}
+	if path, _ := g.ShortestPath(5, 1); path != nil {
+		t.Errorf("Expected no path from 5 to 1, got %v", path)
+	}
+	if path, _ := g.ShortestPath(2, 3); !reflect.DeepEqual(path, []uint64{2, 1, 3}) {
+		t.Errorf("Expected 2 -> 1 -> 3, got %v", path)
+	}
+	if user, _ := store.User(5); user == nil || user.Username != "u5" {
+		t.Errorf("Expected user 5 to be stored, got %+v", user)
+	}
+}
+
+func TestFileStoreReplaysLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.jsonl")
+	server := testServer(t)
+	defer server.Close()
+	followService := follows.NewFollowService(account.NewAccountService(server.URL, ""), nil)
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCrawler(followService, store, &CrawlOptions{Depth: 2}).Crawl(context.Background(), 4); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := store.Followers(4)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if got, _ := store.Followers(4); !reflect.DeepEqual(got, want) || len(got) != 3 {
+		t.Errorf("Expected followers %v after reopening, got %v", want, got)
+	}
+	if user, _ := store.User(3); user == nil || user.Username != "u3" {
+		t.Errorf("Expected user 3 after reopening, got %+v", user)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	store := NewMemoryStore()
+	// 1 follows 2, 3 and 4. 2 and 3 are picky and follow 5, 4 follows 5 and
+	// 6 along with many others.
+	for _, edge := range [][2]uint64{{1, 2}, {1, 3}, {1, 4}, {2, 5}, {3, 5}, {2, 3}, {4, 6}, {4, 7}, {4, 8}, {4, 9}, {4, 5}} {
+		store.AddEdge(edge[0], edge[1])
+	}
+	store.AddUser(&users.User{Fid: 2, Username: "two"})
+	store.AddUser(&users.User{Fid: 5, Username: "five"})
+
+	recommendations, err := Recommend(store, 1, &RecommendOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recommendations) != 2 {
+		t.Fatalf("Expected 2 recommendations, got %d", len(recommendations))
+	}
+	top := recommendations[0]
+	if top.User.Username != "five" || top.FriendsOfFriends != 3 {
+		t.Errorf("Expected five followed by 3 friends first, got %+v", top)
+	}
+	if top.Reason != "Followed by fid 3, @two, fid 4 you follow" {
+		t.Errorf("Unexpected reason %q", top.Reason)
+	}
+	for _, r := range recommendations {
+		if r.User.Fid == 3 || r.User.Fid == 1 {
+			t.Errorf("Expected followed accounts and fid itself to be excluded, got %d", r.User.Fid)
+		}
+	}
+}
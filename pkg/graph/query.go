@@ -0,0 +1,107 @@
+package graph
+
+// Graph answers questions about a crawled follow graph. Results only cover
+// what was crawled: followers of an account that was never crawled, in either
+// direction, are missing.
+type Graph struct {
+	Store
+}
+
+func NewGraph(store Store) *Graph {
+	return &Graph{
+		Store: store,
+	}
+}
+
+// InDegree is the number of known followers of fid.
+func (g *Graph) InDegree(fid uint64) (int, error) {
+	followers, err := g.Followers(fid)
+	return len(followers), err
+}
+
+// OutDegree is the number of known accounts fid follows.
+func (g *Graph) OutDegree(fid uint64) (int, error) {
+	following, err := g.Following(fid)
+	return len(following), err
+}
+
+// Mutuals returns the accounts that follow fid and are followed back.
+func (g *Graph) Mutuals(fid uint64) ([]uint64, error) {
+	followers, err := g.Followers(fid)
+	if err != nil {
+		return nil, err
+	}
+	following, err := g.Following(fid)
+	if err != nil {
+		return nil, err
+	}
+	return intersect(followers, following), nil
+}
+
+// CommonFollowers returns the accounts that follow both a and b.
+func (g *Graph) CommonFollowers(a, b uint64) ([]uint64, error) {
+	followersA, err := g.Followers(a)
+	if err != nil {
+		return nil, err
+	}
+	followersB, err := g.Followers(b)
+	if err != nil {
+		return nil, err
+	}
+	return intersect(followersA, followersB), nil
+}
+
+// ShortestPath returns the shortest chain of follows from one account to
+// another, both included, or nil if there is none.
+func (g *Graph) ShortestPath(from, to uint64) ([]uint64, error) {
+	if from == to {
+		return []uint64{from}, nil
+	}
+	previous := map[uint64]uint64{from: from}
+	queue := []uint64{from}
+	for len(queue) > 0 {
+		fid := queue[0]
+		queue = queue[1:]
+		following, err := g.Following(fid)
+		if err != nil {
+			return nil, err
+		}
+		for _, next := range following {
+			if _, ok := previous[next]; ok {
+				continue
+			}
+			previous[next] = fid
+			if next == to {
+				path := []uint64{to}
+				for at := fid; at != from; at = previous[at] {
+					path = append(path, at)
+				}
+				path = append(path, from)
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path, nil
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil, nil
+}
+
+// intersect expects both lists sorted, as the stores return them.
+func intersect(a, b []uint64) []uint64 {
+	var both []uint64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return both
+}
@@ -0,0 +1,209 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ertan/go-farcaster/pkg/follows"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+// Recommendation is an account suggested to follow, with the signals behind it.
+type Recommendation struct {
+	User  users.User
+	Score float64
+	// FriendsOfFriends is the number of followed accounts that follow the
+	// candidate, listed in Via.
+	FriendsOfFriends int
+	Via              []uint64
+	// AdamicAdar weighs every followed account that follows the candidate by
+	// 1/log(1+n), n being how many accounts it follows, so picky curators
+	// count more than accounts that follow everyone.
+	AdamicAdar float64
+	// FollowerOverlap is the Jaccard similarity between the accounts fid
+	// follows and the known followers of the candidate. It only adds to
+	// FriendsOfFriends when the followers of candidates were crawled too.
+	FollowerOverlap float64
+	// Reason explains the suggestion, e.g. "Followed by @a, @b and 3 others
+	// you follow".
+	Reason string
+}
+
+type RecommendOptions struct {
+	// Limit caps the recommendations. Defaults to 20.
+	Limit int
+	// MinFriends drops candidates followed by fewer followed accounts.
+	// Defaults to 1.
+	MinFriends int
+	// Weights of the signals in the score. All zero means equal weights.
+	FriendsWeight    float64
+	AdamicAdarWeight float64
+	OverlapWeight    float64
+}
+
+// Recommend ranks accounts for fid to follow from a graph that holds fid's
+// following and, for the best results, their following too.
+func Recommend(store Store, fid uint64, options *RecommendOptions) ([]Recommendation, error) {
+	var opts RecommendOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.MinFriends <= 0 {
+		opts.MinFriends = 1
+	}
+	if opts.FriendsWeight == 0 && opts.AdamicAdarWeight == 0 && opts.OverlapWeight == 0 {
+		opts.FriendsWeight, opts.AdamicAdarWeight, opts.OverlapWeight = 1, 1, 1
+	}
+	following, err := store.Following(fid)
+	if err != nil {
+		return nil, err
+	}
+	if len(following) == 0 {
+		return nil, nil
+	}
+	followed := make(map[uint64]bool, len(following))
+	for _, f := range following {
+		followed[f] = true
+	}
+	candidates := make(map[uint64]*Recommendation)
+	for _, friend := range following {
+		theirs, err := store.Following(friend)
+		if err != nil {
+			return nil, err
+		}
+		weight := 1 / math.Log(1+float64(len(theirs)))
+		for _, candidate := range theirs {
+			if candidate == fid || followed[candidate] {
+				continue
+			}
+			r := candidates[candidate]
+			if r == nil {
+				r = &Recommendation{}
+				candidates[candidate] = r
+			}
+			r.FriendsOfFriends++
+			r.Via = append(r.Via, friend)
+			r.AdamicAdar += weight
+		}
+	}
+
+	var ranked []*Recommendation
+	for candidate, r := range candidates {
+		if r.FriendsOfFriends < opts.MinFriends {
+			continue
+		}
+		user, err := store.User(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			user = &users.User{Fid: candidate}
+		}
+		r.User = *user
+		followers, err := store.Followers(candidate)
+		if err != nil {
+			return nil, err
+		}
+		// Via are the followers that fid follows, the rest is the union.
+		r.FollowerOverlap = float64(r.FriendsOfFriends) / float64(len(following)+len(followers)-r.FriendsOfFriends)
+		r.Score = opts.FriendsWeight*float64(r.FriendsOfFriends)/float64(len(following)) +
+			opts.AdamicAdarWeight*r.AdamicAdar +
+			opts.OverlapWeight*r.FollowerOverlap
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].User.Fid < ranked[j].User.Fid
+	})
+	if len(ranked) > opts.Limit {
+		ranked = ranked[:opts.Limit]
+	}
+	recommendations := make([]Recommendation, len(ranked))
+	for i, r := range ranked {
+		reason, err := explain(store, r)
+		if err != nil {
+			return nil, err
+		}
+		r.Reason = reason
+		recommendations[i] = *r
+	}
+	return recommendations, nil
+}
+
+// explain names up to three of the followed accounts behind a suggestion,
+// preferring the ones that follow the fewest accounts.
+func explain(store Store, r *Recommendation) (string, error) {
+	type friend struct {
+		name  string
+		count int
+	}
+	friends := make([]friend, 0, len(r.Via))
+	for _, fid := range r.Via {
+		name := fmt.Sprintf("fid %d", fid)
+		if user, err := store.User(fid); err != nil {
+			return "", err
+		} else if user != nil && user.Username != "" {
+			name = "@" + user.Username
+		}
+		count, err := store.Following(fid)
+		if err != nil {
+			return "", err
+		}
+		friends = append(friends, friend{name, len(count)})
+	}
+	sort.SliceStable(friends, func(i, j int) bool { return friends[i].count < friends[j].count })
+	var names []string
+	for i := 0; i < len(friends) && i < 3; i++ {
+		names = append(names, friends[i].name)
+	}
+	reason := "Followed by " + strings.Join(names, ", ")
+	if others := len(friends) - len(names); others > 0 {
+		reason += fmt.Sprintf(" and %d other", others)
+		if others > 1 {
+			reason += "s"
+		}
+	}
+	return reason + " you follow", nil
+}
+
+// Recommender crawls the neighbourhood of a fid before ranking.
+type Recommender struct {
+	follows *follows.FollowService
+	options RecommendOptions
+	crawl   CrawlOptions
+}
+
+// NewRecommender takes crawl options for the neighbourhood crawl; Depth and
+// Direction are always 2 and Following.
+func NewRecommender(followService *follows.FollowService, options *RecommendOptions, crawl *CrawlOptions) *Recommender {
+	r := &Recommender{
+		follows: followService,
+	}
+	if options != nil {
+		r.options = *options
+	}
+	if crawl != nil {
+		r.crawl = *crawl
+	}
+	r.crawl.Depth = 2
+	r.crawl.Direction = Following
+	return r
+}
+
+// Recommend crawls who fid follows and who they follow, then ranks the
+// accounts fid doesn't follow yet.
+func (r *Recommender) Recommend(ctx context.Context, fid uint64) ([]Recommendation, error) {
+	store := NewMemoryStore()
+	if err := NewCrawler(r.follows, store, &r.crawl).Crawl(ctx, fid); err != nil {
+		return nil, err
+	}
+	return Recommend(store, fid, &r.options)
+}
@@ -0,0 +1,231 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+// Store holds the follow graph. An edge goes from the follower to the followed
+// account. Fid lists are returned sorted.
+type Store interface {
+	AddUser(user *users.User) error
+	AddEdge(follower, following uint64) error
+	// User returns nil if the fid has not been seen.
+	User(fid uint64) (*users.User, error)
+	Following(fid uint64) ([]uint64, error)
+	Followers(fid uint64) ([]uint64, error)
+	// Fids returns every fid that is a user or the end of an edge.
+	Fids() ([]uint64, error)
+}
+
+type MemoryStore struct {
+	mu        sync.RWMutex
+	users     map[uint64]*users.User
+	following map[uint64]map[uint64]struct{}
+	followers map[uint64]map[uint64]struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:     make(map[uint64]*users.User),
+		following: make(map[uint64]map[uint64]struct{}),
+		followers: make(map[uint64]map[uint64]struct{}),
+	}
+}
+
+func (s *MemoryStore) AddUser(user *users.User) error {
+	copied := *user
+	// The viewer context depends on who crawled, it isn't part of the graph.
+	copied.ViewerContext = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.Fid] = &copied
+	return nil
+}
+
+func (s *MemoryStore) AddEdge(follower, following uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEdge(follower, following)
+	return nil
+}
+
+// addEdge reports whether the edge is new.
+func (s *MemoryStore) addEdge(follower, following uint64) bool {
+	if _, ok := s.following[follower][following]; ok {
+		return false
+	}
+	if s.following[follower] == nil {
+		s.following[follower] = make(map[uint64]struct{})
+	}
+	if s.followers[following] == nil {
+		s.followers[following] = make(map[uint64]struct{})
+	}
+	s.following[follower][following] = struct{}{}
+	s.followers[following][follower] = struct{}{}
+	return true
+}
+
+func (s *MemoryStore) User(fid uint64) (*users.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[fid]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *MemoryStore) Following(fid uint64) ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedFids(s.following[fid]), nil
+}
+
+func (s *MemoryStore) Followers(fid uint64) ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedFids(s.followers[fid]), nil
+}
+
+func (s *MemoryStore) Fids() ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make(map[uint64]struct{}, len(s.users))
+	for fid := range s.users {
+		all[fid] = struct{}{}
+	}
+	for fid := range s.following {
+		all[fid] = struct{}{}
+	}
+	for fid := range s.followers {
+		all[fid] = struct{}{}
+	}
+	return sortedFids(all), nil
+}
+
+func sortedFids(set map[uint64]struct{}) []uint64 {
+	fids := make([]uint64, 0, len(set))
+	for fid := range set {
+		fids = append(fids, fid)
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids
+}
+
+// FileStore keeps the graph in memory and appends every change to a JSONL
+// log, which is replayed when the store is opened again.
+type FileStore struct {
+	*MemoryStore
+	file   *os.File
+	writer *bufio.Writer
+}
+
+type logEntry struct {
+	User *users.User `json:"user,omitempty"`
+	Edge []uint64    `json:"edge,omitempty"`
+}
+
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var valid int64
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A crash can leave a partial last line, drop it.
+			break
+		}
+		valid += int64(len(scanner.Bytes())) + 1
+		if entry.User != nil {
+			s.MemoryStore.AddUser(entry.User)
+		}
+		if len(entry.Edge) == 2 {
+			s.MemoryStore.addEdge(entry.Edge[0], entry.Edge[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(valid, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	return s, nil
+}
+
+func (s *FileStore) AddUser(user *users.User) error {
+	if err := s.MemoryStore.AddUser(user); err != nil {
+		return err
+	}
+	stored, _ := s.MemoryStore.User(user.Fid)
+	return s.append(&logEntry{User: stored})
+}
+
+func (s *FileStore) AddEdge(follower, following uint64) error {
+	s.MemoryStore.mu.Lock()
+	added := s.MemoryStore.addEdge(follower, following)
+	s.MemoryStore.mu.Unlock()
+	if !added {
+		return nil
+	}
+	return s.append(&logEntry{Edge: []uint64{follower, following}})
+}
+
+func (s *FileStore) append(entry *logEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.MemoryStore.mu.Lock()
+	defer s.MemoryStore.mu.Unlock()
+	if s.writer == nil {
+		return errors.New("store is closed")
+	}
+	if _, err := s.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Flush writes buffered changes to the log.
+func (s *FileStore) Flush() error {
+	s.MemoryStore.mu.Lock()
+	defer s.MemoryStore.mu.Unlock()
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.Flush()
+}
+
+func (s *FileStore) Close() error {
+	if err := s.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	s.MemoryStore.mu.Lock()
+	s.writer = nil
+	s.MemoryStore.mu.Unlock()
+	return s.file.Close()
+}
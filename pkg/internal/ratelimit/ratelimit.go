@@ -0,0 +1,52 @@
+// Package ratelimit spaces out API calls shared by several workers.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limiter hands out one request slot per tick. A zero rate never waits.
+type Limiter struct {
+	ticker *time.Ticker
+}
+
+// New returns a limiter for perSecond requests. Zero or less, NaN and rates
+// too high for a nanosecond tick mean no limit.
+func New(perSecond float64) *Limiter {
+	if !(perSecond > 0) {
+		return &Limiter{}
+	}
+	interval := float64(time.Second) / perSecond
+	if interval < 1 {
+		return &Limiter{}
+	}
+	tick := time.Duration(math.MaxInt64)
+	if interval < math.MaxInt64 {
+		tick = time.Duration(interval)
+	}
+	return &Limiter{
+		ticker: time.NewTicker(tick),
+	}
+}
+
+// Wait blocks until the next slot or until ctx is done, returning ctx.Err()
+// in that case.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.ticker == nil || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	select {
+	case <-l.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) Stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
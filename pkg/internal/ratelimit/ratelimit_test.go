@@ -0,0 +1,27 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	for _, perSecond := range []float64{0, -1, math.NaN(), 2e9, math.Inf(1)} {
+		limiter := New(perSecond)
+		if limiter.ticker != nil {
+			t.Errorf("Expected no limit for %v", perSecond)
+		}
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Errorf("Expected an unlimited wait to return, got %v", err)
+		}
+	}
+	limiter := New(1e-300)
+	defer limiter.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected a tiny rate to wait, got %v", err)
+	}
+}
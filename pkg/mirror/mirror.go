@@ -0,0 +1,413 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/follows"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+	"github.com/ertan/go-farcaster/pkg/users"
+	_ "modernc.org/sqlite"
+)
+
+type Options struct {
+	// Fids are the accounts to mirror.
+	Fids []uint64
+	// Interval between polls in Run. Defaults to 10 minutes.
+	Interval time.Duration
+	// RefreshWindow is how far back casts are fetched again on every poll so
+	// their counts and reactions stay fresh. Defaults to a day.
+	RefreshWindow time.Duration
+	// PageSize is passed as limit to the paginated endpoints.
+	PageSize int
+	// SkipFollows and SkipReactions turn off mirroring of the social graph
+	// and of cast likes.
+	SkipFollows   bool
+	SkipReactions bool
+	// Logger receives errors from Run. Defaults to the standard logger.
+	Logger *log.Logger
+}
+
+// Mirror keeps a local SQLite copy of the casts, profiles, follows and
+// reactions of a set of fids.
+type Mirror struct {
+	db        *sql.DB
+	users     *users.UserService
+	casts     *casts.CastService
+	follows   *follows.FollowService
+	reactions *reactions.ReactionService
+	options   Options
+	clock     func() time.Time
+}
+
+func (m *Mirror) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock()
+}
+
+// Open opens or creates the database at path.
+func Open(path string, userService *users.UserService, castService *casts.CastService, followService *follows.FollowService, reactionService *reactions.ReactionService, options *Options) (*Mirror, error) {
+	if userService == nil || castService == nil {
+		return nil, errors.New("user and cast services are required")
+	}
+	m := &Mirror{
+		users:     userService,
+		casts:     castService,
+		follows:   followService,
+		reactions: reactionService,
+	}
+	if options != nil {
+		m.options = *options
+	}
+	if m.options.Interval <= 0 {
+		m.options.Interval = 10 * time.Minute
+	}
+	if m.options.RefreshWindow <= 0 {
+		m.options.RefreshWindow = 24 * time.Hour
+	}
+	if m.options.Logger == nil {
+		m.options.Logger = log.Default()
+	}
+	if followService == nil {
+		m.options.SkipFollows = true
+	}
+	if reactionService == nil {
+		m.options.SkipReactions = true
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, a single connection avoids busy errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	m.db = db
+	return m, nil
+}
+
+func (m *Mirror) Close() error {
+	return m.db.Close()
+}
+
+// DB exposes the underlying database for queries the helpers don't cover.
+func (m *Mirror) DB() *sql.DB {
+	return m.db
+}
+
+// Run syncs every Interval until ctx is done. Sync errors are logged and
+// retried on the next poll.
+func (m *Mirror) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m.options.Logger.Println("mirror sync failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches what changed since the last sync for every configured fid.
+func (m *Mirror) Sync(ctx context.Context) error {
+	for _, fid := range m.options.Fids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.SyncFid(ctx, fid); err != nil {
+			return fmt.Errorf("syncing fid %d: %w", fid, err)
+		}
+	}
+	return nil
+}
+
+func (m *Mirror) SyncFid(ctx context.Context, fid uint64) error {
+	user, err := m.users.GetUserByFid(fid)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		if err := m.upsertUsers(ctx, []users.User{*user}); err != nil {
+			return err
+		}
+	}
+	newest, err := m.syncCasts(ctx, fid)
+	if err != nil {
+		return err
+	}
+	if !m.options.SkipFollows {
+		if err := m.syncFollows(ctx, fid); err != nil {
+			return err
+		}
+	}
+	_, err = m.db.ExecContext(ctx,
+		`INSERT INTO sync_state (fid, newest_cast, synced_at) VALUES (?, ?, ?)
+		ON CONFLICT (fid) DO UPDATE SET newest_cast = excluded.newest_cast, synced_at = excluded.synced_at`,
+		fid, newest, m.now().UnixMilli())
+	return err
+}
+
+// syncCasts pages through the casts of fid, newest first, until it reaches
+// casts that were already mirrored and are older than the refresh window.
+func (m *Mirror) syncCasts(ctx context.Context, fid uint64) (uint64, error) {
+	var newest uint64
+	err := m.db.QueryRowContext(ctx, `SELECT newest_cast FROM sync_state WHERE fid = ?`, fid).Scan(&newest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	stop := newest
+	if refresh := uint64(m.now().Add(-m.options.RefreshWindow).UnixMilli()); newest > 0 && refresh < stop {
+		stop = refresh
+	}
+	cursor := ""
+	for {
+		page, next, err := m.casts.GetCastsByFid(fid, m.options.PageSize, cursor)
+		if err != nil {
+			return 0, err
+		}
+		reached := false
+		var fresh []casts.Cast
+		for _, cast := range page {
+			if newest > 0 && cast.Timestamp < stop {
+				reached = true
+				continue
+			}
+			fresh = append(fresh, cast)
+		}
+		changed, err := m.upsertCasts(ctx, fresh)
+		if err != nil {
+			return 0, err
+		}
+		if !m.options.SkipReactions {
+			for _, hash := range changed {
+				if err := m.syncReactions(ctx, hash); err != nil {
+					return 0, err
+				}
+			}
+		}
+		for _, cast := range fresh {
+			if cast.Timestamp > newest {
+				newest = cast.Timestamp
+			}
+		}
+		if reached || next == "" || len(page) == 0 {
+			return newest, nil
+		}
+		cursor = next
+	}
+}
+
+// upsertCasts stores casts and returns the hashes of the ones whose reaction
+// count changed, new casts included.
+func (m *Mirror) upsertCasts(ctx context.Context, page []casts.Cast) ([]string, error) {
+	if len(page) == 0 {
+		return nil, nil
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var changed []string
+	var authors []users.User
+	for _, cast := range page {
+		var stored int
+		err := tx.QueryRowContext(ctx, `SELECT reactions_count FROM casts WHERE hash = ?`, cast.Hash).Scan(&stored)
+		isNew := errors.Is(err, sql.ErrNoRows)
+		if err != nil && !isNew {
+			return nil, err
+		}
+		count := 0
+		if cast.Reactions != nil {
+			count = cast.Reactions.Count
+		}
+		if isNew || stored != count {
+			changed = append(changed, cast.Hash)
+		}
+		if _, err := tx.ExecContext(ctx, upsertCast, castValues(cast)...); err != nil {
+			return nil, err
+		}
+		if cast.Author != nil {
+			authors = append(authors, *cast.Author)
+		}
+	}
+	if err := upsertUsers(ctx, tx, authors, m.now()); err != nil {
+		return nil, err
+	}
+	return changed, tx.Commit()
+}
+
+func (m *Mirror) syncReactions(ctx context.Context, hash string) error {
+	var all []reactions.Reaction
+	cursor := ""
+	for {
+		page, next, err := m.reactions.GetReactionsByCastHash(hash, m.options.PageSize, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			break
+		}
+		cursor = next
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Unlikes don't show up in the listing, so the cast's likes are replaced.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reactions WHERE cast_hash = ?`, hash); err != nil {
+		return err
+	}
+	var reactors []users.User
+	for _, reaction := range all {
+		if reaction.Reactor == nil {
+			continue
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO reactions (cast_hash, reactor_fid, type, hash, timestamp) VALUES (?, ?, ?, ?, ?)`,
+			hash, reaction.Reactor.Fid, reaction.Type, reaction.Hash, reaction.Timestamp)
+		if err != nil {
+			return err
+		}
+		reactors = append(reactors, *reaction.Reactor)
+	}
+	if err := upsertUsers(ctx, tx, reactors, m.now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Mirror) syncFollows(ctx context.Context, fid uint64) error {
+	followers, err := m.allFollows(fid, m.follows.GetFollowersByFid)
+	if err != nil {
+		return err
+	}
+	following, err := m.allFollows(fid, m.follows.GetFollowingByFid)
+	if err != nil {
+		return err
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM follows WHERE follower_fid = ? OR following_fid = ?`, fid, fid); err != nil {
+		return err
+	}
+	for _, user := range followers {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO follows (follower_fid, following_fid) VALUES (?, ?)`, user.Fid, fid); err != nil {
+			return err
+		}
+	}
+	for _, user := range following {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO follows (follower_fid, following_fid) VALUES (?, ?)`, fid, user.Fid); err != nil {
+			return err
+		}
+	}
+	if err := upsertUsers(ctx, tx, append(followers, following...), m.now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Mirror) allFollows(fid uint64, get func(fid uint64, limit int, cursor string) ([]users.User, string, error)) ([]users.User, error) {
+	var all []users.User
+	cursor := ""
+	for {
+		page, next, err := get(fid, m.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" || len(page) == 0 {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+func (m *Mirror) upsertUsers(ctx context.Context, list []users.User) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := upsertUsers(ctx, tx, list, m.now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const upsertUser = `INSERT INTO users (fid, username, display_name, pfp_url, pfp_verified, bio, follower_count, following_count, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (fid) DO UPDATE SET
+	username = excluded.username,
+	display_name = excluded.display_name,
+	pfp_url = excluded.pfp_url,
+	pfp_verified = excluded.pfp_verified,
+	bio = excluded.bio,
+	follower_count = excluded.follower_count,
+	following_count = excluded.following_count,
+	updated_at = excluded.updated_at`
+
+func upsertUsers(ctx context.Context, tx *sql.Tx, list []users.User, now time.Time) error {
+	for _, user := range list {
+		_, err := tx.ExecContext(ctx, upsertUser,
+			user.Fid, user.Username, user.DisplayName, user.Pfp.Url, user.Pfp.Verified,
+			user.Profile.Bio.Text, user.FollowerCount, user.FollowingCount, now.UnixMilli())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const upsertCast = `INSERT INTO casts (hash, thread_hash, parent_hash, author_fid, text, timestamp, replies_count, reactions_count, recasts_count, watches_count)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (hash) DO UPDATE SET
+	replies_count = excluded.replies_count,
+	reactions_count = excluded.reactions_count,
+	recasts_count = excluded.recasts_count,
+	watches_count = excluded.watches_count`
+
+func castValues(cast casts.Cast) []interface{} {
+	authorFid := uint64(0)
+	if cast.Author != nil {
+		authorFid = cast.Author.Fid
+	}
+	replies, reactions, recasts, watches := 0, 0, 0, 0
+	if cast.Replies != nil {
+		replies = cast.Replies.Count
+	}
+	if cast.Reactions != nil {
+		reactions = cast.Reactions.Count
+	}
+	if cast.Recasts != nil {
+		recasts = cast.Recasts.Count
+	}
+	if cast.Watches != nil {
+		watches = cast.Watches.Count
+	}
+	return []interface{}{
+		cast.Hash, cast.ThreadHash, cast.ParentHash, authorFid, cast.Text, cast.Timestamp,
+		replies, reactions, recasts, watches,
+	}
+}
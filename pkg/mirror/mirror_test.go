@@ -0,0 +1,75 @@
+package mirror
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/follows"
+	"github.com/ertan/go-farcaster/pkg/reactions"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestMirrorSync(t *testing.T) {
+	responses := map[string]string{
+		"/v2/user":       `{"result":{"fid":1,"username":"alice","displayName":"Alice","followerCount":1}}`,
+		"/v2/casts":      `{"result":{"casts":[{"hash":"0xb","threadHash":"0xa","parentHash":"0xa","author":{"fid":1,"username":"alice"},"text":"reply","timestamp":2000,"reactions":{"count":1}},{"hash":"0xa","threadHash":"0xa","author":{"fid":1,"username":"alice"},"text":"hello","timestamp":1000,"reactions":{"count":0}}]}}`,
+		"/v2/cast-likes": `{"result":{"likes":[{"type":"like","hash":"0xl","castHash":"0xb","timestamp":2500,"reactor":{"fid":2,"username":"bob"}}]}}`,
+		"/v2/followers":  `{"result":{"users":[{"fid":2,"username":"bob"}]}}`,
+		"/v2/following":  `{"result":{"users":[]}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/cast-likes" && r.URL.Query().Get("castHash") != "0xb" {
+			w.Write([]byte(`{"result":{"likes":[]}}`))
+			return
+		}
+		w.Write([]byte(responses[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	account := account.NewAccountService(server.URL, "")
+	mirror, err := Open(filepath.Join(t.TempDir(), "mirror.db"),
+		users.NewUserService(account, nil),
+		casts.NewCastService(account, nil),
+		follows.NewFollowService(account, nil),
+		reactions.NewReactionService(account),
+		&Options{Fids: []uint64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Close()
+	mirror.clock = func() time.Time { return time.UnixMilli(3000) }
+	ctx := context.Background()
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := mirror.CastsByAuthor(ctx, 1, time.UnixMilli(1500), time.Time{})
+	if err != nil || len(list) != 1 || list[0].Hash != "0xb" || list[0].Author.Username != "alice" {
+		t.Errorf("Expected only 0xb in range, got %+v (%v)", list, err)
+	}
+	replies, err := mirror.Replies(ctx, "0xa")
+	if err != nil || len(replies) != 1 || replies[0].Hash != "0xb" {
+		t.Errorf("Expected 0xb as reply to 0xa, got %+v (%v)", replies, err)
+	}
+	likers, err := mirror.Likers(ctx, "0xb")
+	if err != nil || len(likers) != 1 || likers[0].Username != "bob" {
+		t.Errorf("Expected bob to like 0xb, got %+v (%v)", likers, err)
+	}
+	liked, err := mirror.LikesBy(ctx, 2)
+	if err != nil || len(liked) != 1 || liked[0].Hash != "0xb" {
+		t.Errorf("Expected bob's likes to contain 0xb, got %+v (%v)", liked, err)
+	}
+	followers, err := mirror.Followers(ctx, 1)
+	if err != nil || len(followers) != 1 || followers[0] != 2 {
+		t.Errorf("Expected fid 2 to follow fid 1, got %v (%v)", followers, err)
+	}
+	if synced, _ := mirror.LastSynced(ctx, 1); !synced.Equal(time.UnixMilli(3000)) {
+		t.Errorf("Expected last sync at 3000, got %v", synced)
+	}
+}
@@ -0,0 +1,218 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+const castColumns = `c.hash, c.thread_hash, c.parent_hash, c.text, c.timestamp,
+	c.replies_count, c.reactions_count, c.recasts_count, c.watches_count,
+	c.author_fid, COALESCE(u.username, ''), COALESCE(u.display_name, '')`
+
+// Like is a single like stored in the mirror.
+type Like struct {
+	CastHash   string
+	ReactorFid uint64
+	Type       string
+	Timestamp  time.Time
+}
+
+func (m *Mirror) User(ctx context.Context, fid uint64) (*users.User, error) {
+	list, err := m.queryUsers(ctx, `SELECT `+userColumns+` FROM users WHERE fid = ?`, fid)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("user not found")
+	}
+	return &list[0], nil
+}
+
+func (m *Mirror) UserByUsername(ctx context.Context, username string) (*users.User, error) {
+	list, err := m.queryUsers(ctx, `SELECT `+userColumns+` FROM users WHERE username = ?`, strings.ToLower(username))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("user not found")
+	}
+	return &list[0], nil
+}
+
+func (m *Mirror) Cast(ctx context.Context, hash string) (*casts.Cast, error) {
+	list, err := m.queryCasts(ctx, `SELECT `+castColumns+` FROM casts c LEFT JOIN users u ON u.fid = c.author_fid WHERE c.hash = ?`, hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("cast not found")
+	}
+	return &list[0], nil
+}
+
+// CastsByAuthor returns the casts of fid in [from, to), newest first. A zero
+// time leaves that side of the range open.
+func (m *Mirror) CastsByAuthor(ctx context.Context, fid uint64, from, to time.Time) ([]casts.Cast, error) {
+	query := `SELECT ` + castColumns + ` FROM casts c LEFT JOIN users u ON u.fid = c.author_fid WHERE c.author_fid = ?`
+	args := []interface{}{fid}
+	if !from.IsZero() {
+		query += ` AND c.timestamp >= ?`
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += ` AND c.timestamp < ?`
+		args = append(args, to.UnixMilli())
+	}
+	query += ` ORDER BY c.timestamp DESC`
+	return m.queryCasts(ctx, query, args...)
+}
+
+// Replies returns the direct replies to a cast, oldest first.
+func (m *Mirror) Replies(ctx context.Context, hash string) ([]casts.Cast, error) {
+	return m.queryCasts(ctx, `SELECT `+castColumns+` FROM casts c LEFT JOIN users u ON u.fid = c.author_fid
+		WHERE c.parent_hash = ? ORDER BY c.timestamp`, hash)
+}
+
+// Likers returns the users who liked a cast, most recent first.
+func (m *Mirror) Likers(ctx context.Context, hash string) ([]users.User, error) {
+	return m.queryUsers(ctx, `SELECT `+prefixed(userColumns, "u.")+` FROM reactions r JOIN users u ON u.fid = r.reactor_fid
+		WHERE r.cast_hash = ? ORDER BY r.timestamp DESC`, hash)
+}
+
+// LikesBy returns the mirrored casts fid liked, most recent like first.
+func (m *Mirror) LikesBy(ctx context.Context, fid uint64) ([]casts.Cast, error) {
+	return m.queryCasts(ctx, `SELECT `+castColumns+` FROM reactions r
+		JOIN casts c ON c.hash = r.cast_hash
+		LEFT JOIN users u ON u.fid = c.author_fid
+		WHERE r.reactor_fid = ? ORDER BY r.timestamp DESC`, fid)
+}
+
+// LikesOn returns every like on the casts of fid in [from, to).
+func (m *Mirror) LikesOn(ctx context.Context, fid uint64, from, to time.Time) ([]Like, error) {
+	query := `SELECT r.cast_hash, r.reactor_fid, r.type, r.timestamp FROM reactions r
+		JOIN casts c ON c.hash = r.cast_hash WHERE c.author_fid = ?`
+	args := []interface{}{fid}
+	if !from.IsZero() {
+		query += ` AND r.timestamp >= ?`
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += ` AND r.timestamp < ?`
+		args = append(args, to.UnixMilli())
+	}
+	rows, err := m.db.QueryContext(ctx, query+` ORDER BY r.timestamp DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var likes []Like
+	for rows.Next() {
+		var like Like
+		var timestamp int64
+		if err := rows.Scan(&like.CastHash, &like.ReactorFid, &like.Type, &timestamp); err != nil {
+			return nil, err
+		}
+		like.Timestamp = time.UnixMilli(timestamp)
+		likes = append(likes, like)
+	}
+	return likes, rows.Err()
+}
+
+func (m *Mirror) Followers(ctx context.Context, fid uint64) ([]uint64, error) {
+	return m.queryFids(ctx, `SELECT follower_fid FROM follows WHERE following_fid = ? ORDER BY follower_fid`, fid)
+}
+
+func (m *Mirror) Following(ctx context.Context, fid uint64) ([]uint64, error) {
+	return m.queryFids(ctx, `SELECT following_fid FROM follows WHERE follower_fid = ? ORDER BY following_fid`, fid)
+}
+
+// LastSynced returns when fid was last synced, zero if it never was.
+func (m *Mirror) LastSynced(ctx context.Context, fid uint64) (time.Time, error) {
+	var syncedAt int64
+	err := m.db.QueryRowContext(ctx, `SELECT synced_at FROM sync_state WHERE fid = ?`, fid).Scan(&syncedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(syncedAt), nil
+}
+
+const userColumns = `fid, username, display_name, pfp_url, pfp_verified, bio, follower_count, following_count`
+
+func prefixed(columns, prefix string) string {
+	fields := strings.Split(columns, ", ")
+	for i, field := range fields {
+		fields[i] = prefix + field
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (m *Mirror) queryUsers(ctx context.Context, query string, args ...interface{}) ([]users.User, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []users.User
+	for rows.Next() {
+		var user users.User
+		err := rows.Scan(&user.Fid, &user.Username, &user.DisplayName, &user.Pfp.Url, &user.Pfp.Verified,
+			&user.Profile.Bio.Text, &user.FollowerCount, &user.FollowingCount)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, user)
+	}
+	return list, rows.Err()
+}
+
+func (m *Mirror) queryCasts(ctx context.Context, query string, args ...interface{}) ([]casts.Cast, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []casts.Cast
+	for rows.Next() {
+		cast := casts.Cast{
+			Author:    &users.User{},
+			Replies:   &casts.Replies{},
+			Reactions: &casts.Reactions{},
+			Recasts:   &casts.Recasts{},
+			Watches:   &casts.Watches{},
+		}
+		err := rows.Scan(&cast.Hash, &cast.ThreadHash, &cast.ParentHash, &cast.Text, &cast.Timestamp,
+			&cast.Replies.Count, &cast.Reactions.Count, &cast.Recasts.Count, &cast.Watches.Count,
+			&cast.Author.Fid, &cast.Author.Username, &cast.Author.DisplayName)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, cast)
+	}
+	return list, rows.Err()
+}
+
+func (m *Mirror) queryFids(ctx context.Context, query string, args ...interface{}) ([]uint64, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fids []uint64
+	for rows.Next() {
+		var fid uint64
+		if err := rows.Scan(&fid); err != nil {
+			return nil, err
+		}
+		fids = append(fids, fid)
+	}
+	return fids, rows.Err()
+}
@@ -0,0 +1,54 @@
+package mirror
+
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+	fid             INTEGER PRIMARY KEY,
+	username        TEXT NOT NULL,
+	display_name    TEXT NOT NULL,
+	pfp_url         TEXT NOT NULL,
+	pfp_verified    INTEGER NOT NULL,
+	bio             TEXT NOT NULL,
+	follower_count  INTEGER NOT NULL,
+	following_count INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS users_username ON users (username);
+
+CREATE TABLE IF NOT EXISTS casts (
+	hash            TEXT PRIMARY KEY,
+	thread_hash     TEXT NOT NULL,
+	parent_hash     TEXT NOT NULL,
+	author_fid      INTEGER NOT NULL,
+	text            TEXT NOT NULL,
+	timestamp       INTEGER NOT NULL,
+	replies_count   INTEGER NOT NULL,
+	reactions_count INTEGER NOT NULL,
+	recasts_count   INTEGER NOT NULL,
+	watches_count   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS casts_author_timestamp ON casts (author_fid, timestamp);
+CREATE INDEX IF NOT EXISTS casts_parent ON casts (parent_hash);
+
+CREATE TABLE IF NOT EXISTS follows (
+	follower_fid  INTEGER NOT NULL,
+	following_fid INTEGER NOT NULL,
+	PRIMARY KEY (follower_fid, following_fid)
+);
+CREATE INDEX IF NOT EXISTS follows_following ON follows (following_fid);
+
+CREATE TABLE IF NOT EXISTS reactions (
+	cast_hash   TEXT NOT NULL,
+	reactor_fid INTEGER NOT NULL,
+	type        TEXT NOT NULL,
+	hash        TEXT NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	PRIMARY KEY (cast_hash, reactor_fid, type)
+);
+CREATE INDEX IF NOT EXISTS reactions_reactor ON reactions (reactor_fid, timestamp);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+	fid         INTEGER PRIMARY KEY,
+	newest_cast INTEGER NOT NULL,
+	synced_at   INTEGER NOT NULL
+);
+`
@@ -0,0 +1,309 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindReply   Kind = "reply"
+	KindMention Kind = "mention"
+	KindLike    Kind = "like"
+	KindRecast  Kind = "recast"
+	KindFollow  Kind = "follow"
+	KindUnknown Kind = "unknown"
+)
+
+// Kind maps the free form Type of the API to a typed kind.
+func (n *Notification) Kind() Kind {
+	t := strings.ToLower(n.Type)
+	switch {
+	case strings.Contains(t, "reply"):
+		return KindReply
+	case strings.Contains(t, "mention"):
+		return KindMention
+	case strings.Contains(t, "recast"):
+		return KindRecast
+	case strings.Contains(t, "like"), strings.Contains(t, "reaction"):
+		return KindLike
+	case strings.Contains(t, "follow"):
+		return KindFollow
+	}
+	return KindUnknown
+}
+
+// Mark is the high-water mark of a watcher: the newest timestamp it emitted and
+// the ids emitted at that timestamp, so notifications sharing it aren't lost
+// or repeated.
+type Mark struct {
+	Timestamp uint64   `json:"timestamp"`
+	Ids       []string `json:"ids"`
+}
+
+func (m *Mark) seen(n *Notification) bool {
+	if n.Timestamp != m.Timestamp {
+		return n.Timestamp < m.Timestamp
+	}
+	for _, id := range m.Ids {
+		if id == n.Id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mark) advance(n *Notification) {
+	if n.Timestamp > m.Timestamp {
+		m.Timestamp = n.Timestamp
+		m.Ids = nil
+	}
+	m.Ids = append(m.Ids, n.Id)
+}
+
+// Checkpoint persists the mark between runs.
+type Checkpoint interface {
+	Load() (*Mark, error)
+	Save(mark *Mark) error
+}
+
+// FileCheckpoint stores the mark as JSON in a file.
+type FileCheckpoint struct {
+	path string
+}
+
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{
+		path: path,
+	}
+}
+
+func (c *FileCheckpoint) Load() (*Mark, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mark Mark
+	if err := json.Unmarshal(data, &mark); err != nil {
+		return nil, err
+	}
+	return &mark, nil
+}
+
+func (c *FileCheckpoint) Save(mark *Mark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(c.path+".tmp", c.path)
+}
+
+// MemoryCheckpoint keeps the mark in memory, mostly useful in tests.
+type MemoryCheckpoint struct {
+	mu   sync.Mutex
+	mark *Mark
+}
+
+func (c *MemoryCheckpoint) Load() (*Mark, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mark == nil {
+		return nil, nil
+	}
+	mark := *c.mark
+	return &mark, nil
+}
+
+func (c *MemoryCheckpoint) Save(mark *Mark) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copied := *mark
+	c.mark = &copied
+	return nil
+}
+
+type WatchOptions struct {
+	// Checkpoint persists the high-water mark. Without one the watcher starts
+	// from scratch on every run.
+	Checkpoint Checkpoint
+	// Kinds limits the emitted notifications to these kinds. The mark still
+	// advances past the others.
+	Kinds []Kind
+	// SkipBacklog drops the notifications that exist before the first poll
+	// when there is no saved mark.
+	SkipBacklog bool
+	// PageSize is passed as limit to GetNotifications.
+	PageSize int
+	// MaxPages caps how far back a single poll pages. Defaults to 10.
+	MaxPages int
+	// MaxBackoff caps the wait between polls after errors. Defaults to
+	// 10 times the interval.
+	MaxBackoff time.Duration
+	// OnError is called with every polling or checkpoint error.
+	OnError func(err error)
+}
+
+// Watch polls notifications every interval and emits the unseen ones, oldest
+// first. The channel is closed when ctx is done.
+func (n *NotificationService) Watch(ctx context.Context, interval time.Duration, options *WatchOptions) (<-chan Notification, error) {
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	w := &watcher{
+		service:  n,
+		interval: interval,
+	}
+	if options != nil {
+		w.options = *options
+	}
+	if w.options.MaxPages <= 0 {
+		w.options.MaxPages = 10
+	}
+	if w.options.MaxBackoff <= 0 {
+		w.options.MaxBackoff = 10 * interval
+	}
+	if w.options.Checkpoint != nil {
+		mark, err := w.options.Checkpoint.Load()
+		if err != nil {
+			return nil, err
+		}
+		w.mark = mark
+	}
+	out := make(chan Notification)
+	go w.run(ctx, out)
+	return out, nil
+}
+
+type watcher struct {
+	service  *NotificationService
+	interval time.Duration
+	options  WatchOptions
+	mark     *Mark
+}
+
+func (w *watcher) run(ctx context.Context, out chan<- Notification) {
+	defer close(out)
+	wait := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		unseen, err := w.poll()
+		if err != nil {
+			w.report(err)
+			// Back off exponentially until a poll succeeds.
+			if wait < w.interval {
+				wait = w.interval
+			}
+			wait *= 2
+			if wait > w.options.MaxBackoff {
+				wait = w.options.MaxBackoff
+			}
+			continue
+		}
+		wait = w.interval
+		for i := range unseen {
+			notification := unseen[i]
+			if w.wanted(&notification) {
+				select {
+				case out <- notification:
+				case <-ctx.Done():
+					return
+				}
+			}
+			w.mark.advance(&notification)
+			if w.options.Checkpoint != nil {
+				if err := w.options.Checkpoint.Save(w.mark); err != nil {
+					w.report(err)
+				}
+			}
+		}
+	}
+}
+
+// poll returns the notifications past the mark, oldest first.
+func (w *watcher) poll() ([]Notification, error) {
+	var unseen []Notification
+	cursor := ""
+	for page := 0; page < w.options.MaxPages; page++ {
+		list, next, err := w.service.GetNotifications(w.options.PageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		reached := false
+		for _, notification := range list {
+			if w.mark != nil && w.mark.seen(&notification) {
+				reached = true
+				continue
+			}
+			unseen = append(unseen, notification)
+		}
+		// Without a mark only the first page is read.
+		if w.mark == nil || reached || next == "" || len(list) == 0 {
+			break
+		}
+		cursor = next
+	}
+	sort.SliceStable(unseen, func(i, j int) bool {
+		return unseen[i].Timestamp < unseen[j].Timestamp
+	})
+	if w.mark == nil {
+		w.mark = &Mark{}
+		if w.options.SkipBacklog {
+			for i := range unseen {
+				w.mark.advance(&unseen[i])
+			}
+			if w.options.Checkpoint != nil {
+				if err := w.options.Checkpoint.Save(w.mark); err != nil {
+					w.report(err)
+				}
+			}
+			return nil, nil
+		}
+	}
+	// Pages can overlap while new notifications arrive, drop duplicates.
+	seen := make(map[string]bool, len(unseen))
+	deduped := unseen[:0]
+	for _, notification := range unseen {
+		if seen[notification.Id] {
+			continue
+		}
+		seen[notification.Id] = true
+		deduped = append(deduped, notification)
+	}
+	return deduped, nil
+}
+
+func (w *watcher) wanted(n *Notification) bool {
+	if len(w.options.Kinds) == 0 {
+		return true
+	}
+	kind := n.Kind()
+	for _, k := range w.options.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watcher) report(err error) {
+	if w.options.OnError != nil {
+		w.options.OnError(err)
+	}
+}
@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestWatchEmitsUnseenNotifications(t *testing.T) {
+	var mu sync.Mutex
+	items := []string{
+		`{"id":"b","type":"cast-reply","timestamp":2}`,
+		`{"id":"a","type":"follow","timestamp":1}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"result":{"notifications":[%s]}}`, strings.Join(items, ","))
+	}))
+	defer server.Close()
+
+	service := NewNotificationService(account.NewAccountService(server.URL, ""))
+	checkpoint := &MemoryCheckpoint{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := service.Watch(ctx, 10*time.Millisecond, &WatchOptions{Checkpoint: checkpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-watch, <-watch
+	if first.Id != "a" || second.Id != "b" || second.Kind() != KindReply {
+		t.Fatalf("Expected a then the reply b, got %s and %s", first.Id, second.Id)
+	}
+
+	mu.Lock()
+	items = append([]string{`{"id":"c","type":"cast-mention","timestamp":2}`}, items...)
+	mu.Unlock()
+	select {
+	case n := <-watch:
+		if n.Id != "c" || n.Kind() != KindMention {
+			t.Errorf("Expected the mention c, got %s", n.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for c")
+	}
+	select {
+	case n := <-watch:
+		t.Errorf("Expected no more notifications, got %s", n.Id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	mark, _ := checkpoint.Load()
+	if mark.Timestamp != 2 || len(mark.Ids) != 2 {
+		t.Errorf("Expected mark at 2 with b and c, got %+v", mark)
+	}
+}
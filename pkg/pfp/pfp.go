@@ -0,0 +1,262 @@
+package pfp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	// Formats the fetcher can decode.
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+var ErrNoPfp = errors.New("user has no pfp")
+
+// ContentTypes are the accepted pfp formats. The standard library has no
+// WebP or SVG decoder, those pfps fail with an UnsupportedError.
+var ContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type UnsupportedError struct {
+	ContentType string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported pfp content type %q", e.ContentType)
+}
+
+type Options struct {
+	// MaxBytes caps the download size. Defaults to 5 MB.
+	MaxBytes int64
+	// MaxPixels caps the dimensions of a pfp, checked before it is decoded
+	// since a small file can declare a huge image. Defaults to 16 megapixels.
+	MaxPixels int
+	// MaxSize caps the requested thumbnail size. Defaults to 1024.
+	MaxSize int
+	// HTTPClient defaults to a client with a 30 second timeout that only
+	// connects to public addresses, since pfp urls are chosen by users. A
+	// custom client is used as is.
+	HTTPClient *http.Client
+	// AllowPrivateNetworks lets the default client fetch pfps from loopback,
+	// private and link-local addresses.
+	AllowPrivateNetworks bool
+}
+
+// Fetcher downloads pfps and keeps them with their square thumbnails under
+// <dir>/<fid>/<hash of the url>/. A new pfp url gets a new directory and the
+// old one is removed, so thumbnails refresh when a user changes their pfp.
+type Fetcher struct {
+	users   *users.UserService
+	dir     string
+	options Options
+	mu      sync.Mutex
+	locks   map[users.Fid]*sync.Mutex
+}
+
+func NewFetcher(userService *users.UserService, dir string, options *Options) (*Fetcher, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f := &Fetcher{
+		users: userService,
+		dir:   dir,
+		locks: make(map[users.Fid]*sync.Mutex),
+	}
+	if options != nil {
+		f.options = *options
+	}
+	if f.options.MaxBytes <= 0 {
+		f.options.MaxBytes = 5 << 20
+	}
+	if f.options.MaxPixels <= 0 {
+		f.options.MaxPixels = 16 << 20
+	}
+	if f.options.MaxSize <= 0 {
+		f.options.MaxSize = 1024
+	}
+	if f.options.HTTPClient == nil {
+		if f.options.AllowPrivateNetworks {
+			f.options.HTTPClient = &http.Client{Timeout: 30 * time.Second, CheckRedirect: checkRedirect}
+		} else {
+			f.options.HTTPClient = publicClient(30 * time.Second)
+		}
+	}
+	return f, nil
+}
+
+// GetByFid fetches the user and returns the path of its thumbnail.
+func (f *Fetcher) GetByFid(fid users.Fid, size int) (string, error) {
+	if f.users == nil {
+		return "", errors.New("user service is not set")
+	}
+	user, err := f.users.GetUserByFid(fid)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", fmt.Errorf("user %d not found", fid)
+	}
+	return f.Get(user, size)
+}
+
+// Get returns the path of a size x size PNG thumbnail of the user's pfp,
+// downloading the pfp if its url isn't cached yet.
+func (f *Fetcher) Get(user *users.User, size int) (string, error) {
+	if size <= 0 || size > f.options.MaxSize {
+		return "", fmt.Errorf("size must be between 1 and %d", f.options.MaxSize)
+	}
+	if user.Pfp.Url == "" {
+		return "", ErrNoPfp
+	}
+	lock := f.lock(user.Fid)
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir := f.pfpDir(user)
+	thumbnail := filepath.Join(dir, strconv.Itoa(size)+".png")
+	if _, err := os.Stat(thumbnail); err == nil {
+		return thumbnail, nil
+	}
+	original, err := f.original(user, dir)
+	if err != nil {
+		return "", err
+	}
+	resized := Thumbnail(original, size)
+	var b bytes.Buffer
+	if err := png.Encode(&b, resized); err != nil {
+		return "", err
+	}
+	if err := writeFile(thumbnail, b.Bytes()); err != nil {
+		return "", err
+	}
+	return thumbnail, nil
+}
+
+func (f *Fetcher) lock(fid users.Fid) *sync.Mutex {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lock, ok := f.locks[fid]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.locks[fid] = lock
+	}
+	return lock
+}
+
+func (f *Fetcher) pfpDir(user *users.User) string {
+	sum := sha256.Sum256([]byte(user.Pfp.Url))
+	return filepath.Join(f.dir, strconv.FormatUint(user.Fid, 10), hex.EncodeToString(sum[:8]))
+}
+
+// original decodes the cached download or fetches it, removing the pfps of
+// previous urls. A cached download that doesn't decode is fetched again.
+func (f *Fetcher) original(user *users.User, dir string) (image.Image, error) {
+	path := filepath.Join(dir, "original")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if img, err := f.decode(data); err == nil {
+			return img, nil
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	data, err = f.download(user.Pfp.Url)
+	if err != nil {
+		return nil, err
+	}
+	img, err := f.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	fidDir := filepath.Dir(dir)
+	entries, _ := os.ReadDir(fidDir)
+	for _, entry := range entries {
+		if entry.IsDir() && filepath.Join(fidDir, entry.Name()) != dir {
+			os.RemoveAll(filepath.Join(fidDir, entry.Name()))
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := writeFile(path, data); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func (f *Fetcher) decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding pfp: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > f.options.MaxPixels/config.Height {
+		return nil, fmt.Errorf("pfp of %dx%d pixels is larger than %d pixels", config.Width, config.Height, f.options.MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding pfp: %w", err)
+	}
+	return img, nil
+}
+
+func (f *Fetcher) download(rawUrl string) ([]byte, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUrl(u); err != nil {
+		return nil, err
+	}
+	response, err := f.options.HTTPClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching pfp: %s", response.Status)
+	}
+	contentType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if !ContentTypes[contentType] {
+		return nil, &UnsupportedError{ContentType: contentType}
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, f.options.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.options.MaxBytes {
+		return nil, fmt.Errorf("pfp is larger than %d bytes", f.options.MaxBytes)
+	}
+	// Hosts mislabel files, trust the content over the header.
+	if sniffed := http.DetectContentType(data); !ContentTypes[sniffed] {
+		return nil, &UnsupportedError{ContentType: sniffed}
+	}
+	return data, nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
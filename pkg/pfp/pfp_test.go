@@ -0,0 +1,152 @@
+package pfp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func TestFetcher(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			src.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	png.Encode(&encoded, src)
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		if r.URL.Path == "/page.html" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		if r.URL.Path == "/huge.png" {
+			w.Write(hugePNG(encoded.Bytes(), 30000, 30000))
+			return
+		}
+		w.Write(encoded.Bytes())
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fetcher, err := NewFetcher(nil, dir, &Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &users.User{Fid: 3, Pfp: users.Pfp{Url: server.URL + "/a.png"}}
+	path, err := fetcher.Get(user, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.Open(path)
+	thumbnail, err := png.Decode(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumbnail.Bounds().Dx() != 8 || thumbnail.Bounds().Dy() != 8 {
+		t.Errorf("Expected an 8x8 thumbnail, got %v", thumbnail.Bounds())
+	}
+	if r, _, _, a := thumbnail.At(4, 4).RGBA(); r != 0xffff || a != 0xffff {
+		t.Errorf("Expected opaque red, got %v", thumbnail.At(4, 4))
+	}
+
+	if _, err := fetcher.Get(user, 16); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("Expected a new size to reuse the download, got %d downloads", n)
+	}
+
+	user.Pfp.Url = server.URL + "/b.png"
+	if _, err := fetcher.Get(user, 8); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "3"))
+	if n := atomic.LoadInt32(&downloads); n != 2 || len(entries) != 1 {
+		t.Errorf("Expected a new url to be downloaded and replace the old one, got %d downloads and %d dirs", n, len(entries))
+	}
+
+	// A corrupt cached download is fetched again.
+	pfpDir := filepath.Join(dir, "3", entries[0].Name())
+	os.WriteFile(filepath.Join(pfpDir, "original"), []byte("garbage"), 0o644)
+	os.Remove(filepath.Join(pfpDir, "8.png"))
+	if _, err := fetcher.Get(user, 8); err != nil {
+		t.Errorf("Expected a corrupt original to be replaced, got %v", err)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 3 {
+		t.Errorf("Expected the corrupt original to be downloaded again, got %d downloads", n)
+	}
+
+	if _, err := fetcher.Get(user, 5000); err == nil {
+		t.Errorf("Expected a size above MaxSize to fail")
+	}
+
+	user.Pfp.Url = server.URL + "/page.html"
+	var unsupported *UnsupportedError
+	if _, err := fetcher.Get(user, 8); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an unsupported content type, got %v", err)
+	}
+
+	user.Pfp.Url = server.URL + "/huge.png"
+	if _, err := fetcher.Get(user, 8); err == nil || !strings.Contains(err.Error(), "30000x30000") {
+		t.Errorf("Expected a huge pfp to be rejected before decoding, got %v", err)
+	}
+}
+
+func TestFetcherRejectsPrivateUrls(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(nil, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{server.URL + "/a.png", "http://169.254.169.254/latest/meta-data", "file:///etc/passwd"} {
+		user := &users.User{Fid: 3, Pfp: users.Pfp{Url: url}}
+		if _, err := fetcher.Get(user, 8); err == nil {
+			t.Errorf("Expected %s to be rejected", url)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("Expected no request to reach the loopback server, got %d", n)
+	}
+
+	fetcher, _ = NewFetcher(nil, t.TempDir(), &Options{AllowPrivateNetworks: true})
+	user := &users.User{Fid: 3, Pfp: users.Pfp{Url: server.URL + "/a.png"}}
+	if _, err := fetcher.Get(user, 8); err == nil || !strings.Contains(err.Error(), "scheme") {
+		t.Errorf("Expected a redirect to a file url to be rejected, got %v", err)
+	}
+}
+
+// hugePNG returns a valid PNG whose header declares width x height pixels.
+func hugePNG(png []byte, width, height uint32) []byte {
+	huge := append([]byte(nil), png...)
+	// The IHDR chunk follows the 8 byte signature: length, type, width,
+	// height, 5 more bytes and the CRC of type and data.
+	binary.BigEndian.PutUint32(huge[16:], width)
+	binary.BigEndian.PutUint32(huge[20:], height)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+	return huge
+}
@@ -0,0 +1,163 @@
+package reactions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/internal/ratelimit"
+)
+
+type BatchAction string
+
+const (
+	BatchReact   BatchAction = "react"
+	BatchUnreact BatchAction = "unreact"
+	BatchRecast  BatchAction = "recast"
+)
+
+type BatchStatus string
+
+const (
+	BatchDone BatchStatus = "done"
+	// BatchSkipped means the viewer already (un)reacted or recast the cast.
+	BatchSkipped BatchStatus = "skipped"
+	BatchFailed  BatchStatus = "failed"
+)
+
+// BatchResult is the outcome for one cast hash.
+type BatchResult struct {
+	Hash   string      `json:"hash"`
+	Action BatchAction `json:"action"`
+	Status BatchStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Time   time.Time   `json:"time"`
+}
+
+type BatchOptions struct {
+	// Casts looks up the viewer context of every cast to skip the ones that
+	// were already (un)reacted or recast. Without it nothing is skipped.
+	Casts *casts.CastService
+	// Concurrency is the number of casts processed in parallel. Defaults to 4.
+	Concurrency int
+	// RequestsPerSecond caps the API calls across workers, lookups included.
+	// Zero means no limit.
+	RequestsPerSecond float64
+}
+
+// ReactToCasts likes every cast. A failure doesn't stop the batch, results
+// are returned in the order of hashes. A hash listed twice is only acted on
+// once and both entries get the same result. Casts not reached before ctx is
+// done fail with ctx.Err().
+func (r *ReactionService) ReactToCasts(ctx context.Context, hashes []string, options *BatchOptions) []BatchResult {
+	return r.batch(ctx, BatchReact, hashes, options)
+}
+
+// UnreactToCasts removes the likes of every cast, see ReactToCasts.
+func (r *ReactionService) UnreactToCasts(ctx context.Context, hashes []string, options *BatchOptions) []BatchResult {
+	return r.batch(ctx, BatchUnreact, hashes, options)
+}
+
+// RecastCasts recasts every cast, see ReactToCasts.
+func (r *ReactionService) RecastCasts(ctx context.Context, hashes []string, options *BatchOptions) []BatchResult {
+	return r.batch(ctx, BatchRecast, hashes, options)
+}
+
+func (r *ReactionService) batch(ctx context.Context, action BatchAction, hashes []string, options *BatchOptions) []BatchResult {
+	var opts BatchOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	limit := ratelimit.New(opts.RequestsPerSecond)
+	defer limit.Stop()
+	results := make([]BatchResult, len(hashes))
+	first := make(map[string]int, len(hashes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = r.batchOne(ctx, action, hashes[index], &opts, limit)
+			}
+		}()
+	}
+	for index, hash := range hashes {
+		if _, ok := first[hash]; ok {
+			continue
+		}
+		first[hash] = index
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	for index, hash := range hashes {
+		results[index] = results[first[hash]]
+	}
+	return results
+}
+
+func (r *ReactionService) batchOne(ctx context.Context, action BatchAction, hash string, opts *BatchOptions, limit *ratelimit.Limiter) BatchResult {
+	result := BatchResult{
+		Hash:   hash,
+		Action: action,
+	}
+	fail := func(err error) BatchResult {
+		result.Status = BatchFailed
+		result.Error = err.Error()
+		result.Time = time.Now()
+		return result
+	}
+	if opts.Casts != nil {
+		if err := limit.Wait(ctx); err != nil {
+			return fail(err)
+		}
+		cast, err := opts.Casts.GetCastByHash(hash)
+		if err != nil {
+			return fail(err)
+		}
+		if cast == nil {
+			return fail(errors.New("cast not found"))
+		}
+		if cast.ViewerContext != nil {
+			var skip bool
+			switch action {
+			case BatchReact:
+				skip = cast.ViewerContext.Reacted
+			case BatchUnreact:
+				skip = !cast.ViewerContext.Reacted
+			case BatchRecast:
+				skip = cast.ViewerContext.Recast
+			}
+			if skip {
+				result.Status = BatchSkipped
+				result.Time = time.Now()
+				return result
+			}
+		}
+	}
+	if err := limit.Wait(ctx); err != nil {
+		return fail(err)
+	}
+	var err error
+	switch action {
+	case BatchReact:
+		_, err = r.ReactToCast(hash)
+	case BatchUnreact:
+		err = r.UnreactToCast(hash)
+	case BatchRecast:
+		_, err = r.RecastCast(hash)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result.Status = BatchDone
+	result.Time = time.Now()
+	return result
+}
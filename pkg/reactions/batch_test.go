@@ -0,0 +1,75 @@
+package reactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+func TestReactToCasts(t *testing.T) {
+	var mu sync.Mutex
+	var liked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/cast":
+			hash := r.URL.Query().Get("hash")
+			// 0xa is already liked by the viewer.
+			fmt.Fprintf(w, `{"result":{"cast":{"hash":"%s","viewerContext":{"reacted":%t}}}}`, hash, hash == "0xa")
+		case "/v2/cast-likes":
+			var request struct {
+				CastHash string `json:"castHash"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			if request.CastHash == "0xc" {
+				fmt.Fprint(w, `{"errors":[{"message":"cast is deleted"}]}`)
+				return
+			}
+			mu.Lock()
+			liked = append(liked, request.CastHash)
+			mu.Unlock()
+			fmt.Fprintf(w, `{"result":{"like":{"type":"like","castHash":"%s"}}}`, request.CastHash)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	accountService := account.NewAccountService(server.URL, "")
+	reactionService := NewReactionService(accountService)
+	options := &BatchOptions{
+		Casts:             casts.NewCastService(accountService, nil),
+		Concurrency:       2,
+		RequestsPerSecond: 1000,
+	}
+	results := reactionService.ReactToCasts(context.Background(), []string{"0xa", "0xb", "0xc", "0xd", "0xb"}, options)
+	expected := []BatchStatus{BatchSkipped, BatchDone, BatchFailed, BatchDone, BatchDone}
+	for i, result := range results {
+		if result.Status != expected[i] {
+			t.Errorf("Expected %s for %s, got %s (%s)", expected[i], result.Hash, result.Status, result.Error)
+		}
+	}
+	if results[2].Error != "cast is deleted" {
+		t.Errorf("Expected the API error for 0xc, got %q", results[2].Error)
+	}
+	if len(liked) != 2 {
+		t.Errorf("Expected 2 likes with 0xb liked once, got %v", liked)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range reactionService.ReactToCasts(ctx, []string{"0xe", "0xf"}, options) {
+		if result.Status != BatchFailed || result.Error != context.Canceled.Error() {
+			t.Errorf("Expected a canceled batch to fail, got %+v", result)
+		}
+	}
+	if len(liked) != 2 {
+		t.Errorf("Expected no likes after cancel, got %v", liked)
+	}
+}
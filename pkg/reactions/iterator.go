@@ -0,0 +1,124 @@
+package reactions
+
+import "context"
+
+// ReactionIterator pages through reactions lazily, fetching the next page
+// only once the current one is consumed.
+//
+//	it := reactionService.UserReactions(fid, 100)
+//	for it.Next() {
+//		fmt.Println(it.Reaction().Reactor.Username)
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type ReactionIterator struct {
+	fetch   func(limit int, cursor string) ([]Reaction, string, error)
+	limit   int
+	page    []Reaction
+	current Reaction
+	cursor  string
+	// pageCursor is the cursor the page being consumed was fetched with.
+	pageCursor string
+	started    bool
+	done       bool
+	err        error
+}
+
+// CastReactions iterates over the likes of a cast. pageSize is passed as
+// limit, 0 uses the API default.
+func (r *ReactionService) CastReactions(hash string, pageSize int) *ReactionIterator {
+	return &ReactionIterator{
+		fetch: func(limit int, cursor string) ([]Reaction, string, error) {
+			return r.GetReactionsByCastHash(hash, limit, cursor)
+		},
+		limit: pageSize,
+	}
+}
+
+// UserReactions iterates over the likes of a user.
+func (r *ReactionService) UserReactions(fid uint64, pageSize int) *ReactionIterator {
+	return &ReactionIterator{
+		fetch: func(limit int, cursor string) ([]Reaction, string, error) {
+			return r.GetUserReactions(fid, limit, cursor)
+		},
+		limit: pageSize,
+	}
+}
+
+// Next advances to the next reaction, fetching a page if needed. It returns
+// false once every reaction was returned or a request failed.
+func (it *ReactionIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil || (it.started && it.cursor == "") {
+			return false
+		}
+		page, next, err := it.fetch(it.limit, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.started = true
+		it.pageCursor = it.cursor
+		it.page = page
+		it.cursor = next
+		if len(page) == 0 {
+			it.done = true
+		}
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Reaction returns the reaction Next advanced to.
+func (it *ReactionIterator) Reaction() Reaction {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ReactionIterator) Err() error {
+	return it.err
+}
+
+// Cursor returns a cursor to resume from later with GetReactionsByCastHash
+// or GetUserReactions. The API only pages from page boundaries, so while a
+// page is partly consumed this is the cursor of that page and resuming
+// repeats the reactions of it already returned rather than skipping the rest.
+// The cursor is empty both before the first page and after the last one, use
+// Done to tell them apart.
+func (it *ReactionIterator) Cursor() string {
+	if len(it.page) > 0 {
+		return it.pageCursor
+	}
+	return it.cursor
+}
+
+// Done reports whether every reaction was returned. It is false after a
+// failed request, resuming from Cursor picks up where it stopped.
+func (it *ReactionIterator) Done() bool {
+	if it.err != nil || len(it.page) > 0 {
+		return false
+	}
+	return it.done || (it.started && it.cursor == "")
+}
+
+// Stream sends the reactions on the returned channel as their pages come in.
+// The channel is closed when the reactions run out, a request fails or ctx
+// is done. Err tells a failure or ctx.Err() apart from the end of the
+// reactions once the channel is closed.
+func (it *ReactionIterator) Stream(ctx context.Context) <-chan Reaction {
+	reactions := make(chan Reaction)
+	go func() {
+		defer close(reactions)
+		for it.Next() {
+			select {
+			case reactions <- it.Reaction():
+			case <-ctx.Done():
+				it.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return reactions
+}
@@ -0,0 +1,112 @@
+package reactions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+// reactionServer serves total likes for any fid or cast, limit per page.
+func reactionServer(t *testing.T, total int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/user-cast-likes" && r.URL.Path != "/v2/cast-likes" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		likes := ""
+		for i := start; i < total && i < start+limit; i++ {
+			if likes != "" {
+				likes += ","
+			}
+			likes += fmt.Sprintf(`{"type":"like","hash":"0x%d","castHash":"0xc"}`, i)
+		}
+		next := ""
+		if start+limit < total {
+			next = strconv.Itoa(start + limit)
+		}
+		fmt.Fprintf(w, `{"result":{"likes":[%s]},"next":{"cursor":"%s"}}`, likes, next)
+	}))
+}
+
+func TestGetUserReactionsPaginates(t *testing.T) {
+	server := reactionServer(t, 3)
+	defer server.Close()
+	reactionService := NewReactionService(account.NewAccountService(server.URL, ""))
+
+	page, cursor, err := reactionService.GetUserReactions(1, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || cursor != "2" {
+		t.Fatalf("Expected 2 reactions and cursor 2, got %d and %q", len(page), cursor)
+	}
+	page, cursor, err = reactionService.GetUserReactions(1, 2, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Hash != "0x2" || cursor != "" {
+		t.Errorf("Expected the last reaction without a cursor, got %+v and %q", page, cursor)
+	}
+}
+
+func TestReactionIterator(t *testing.T) {
+	server := reactionServer(t, 5)
+	defer server.Close()
+	reactionService := NewReactionService(account.NewAccountService(server.URL, ""))
+
+	it := reactionService.UserReactions(1, 2)
+	it.Next()
+	it.Next()
+	it.Next()
+	if cursor := it.Cursor(); cursor != "2" {
+		t.Errorf("Expected the cursor of the partly consumed page, got %q", cursor)
+	}
+	it.Next()
+	if cursor := it.Cursor(); cursor != "4" {
+		t.Errorf("Expected the next cursor at a page boundary, got %q", cursor)
+	}
+
+	it = reactionService.UserReactions(1, 2)
+	if it.Done() || it.Cursor() != "" {
+		t.Errorf("Expected a new iterator to start from the beginning")
+	}
+	n := 0
+	for it.Next() {
+		if it.Done() != (n == 4) {
+			t.Errorf("Expected the iterator to be done after the last reaction only, got %t at 0x%d", it.Done(), n)
+		}
+		if hash := it.Reaction().Hash; hash != fmt.Sprintf("0x%d", n) {
+			t.Errorf("Expected 0x%d, got %s", n, hash)
+		}
+		n++
+	}
+	if it.Err() != nil || n != 5 || !it.Done() {
+		t.Errorf("Expected 5 reactions and a done iterator, got %d (%v)", n, it.Err())
+	}
+
+	it = reactionService.CastReactions("0xc", 2)
+	n = 0
+	for range it.Stream(context.Background()) {
+		n++
+	}
+	if it.Err() != nil || n != 5 {
+		t.Errorf("Expected 5 streamed reactions, got %d (%v)", n, it.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it = reactionService.CastReactions("0xc", 2)
+	stream := it.Stream(ctx)
+	<-stream
+	cancel()
+	for range stream {
+	}
+	if it.Err() != context.Canceled {
+		t.Errorf("Expected the stream to stop with context.Canceled, got %v", it.Err())
+	}
+}
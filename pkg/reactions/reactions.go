@@ -5,11 +5,13 @@ import (
 	"errors"
 
 	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
 	"github.com/ertan/go-farcaster/pkg/users"
 )
 
 type ReactionService struct {
 	account *account.AccountService
+	cache   *cache.Cache
 }
 
 type Reaction struct {
@@ -26,6 +28,12 @@ func NewReactionService(account *account.AccountService) *ReactionService {
 	}
 }
 
+// SetCache sets the cache whose casts are invalidated when their reactions
+// change, usually the one of the CastService.
+func (r *ReactionService) SetCache(c *cache.Cache) {
+	r.cache = c
+}
+
 func (r *ReactionService) GetReactionsByCastHash(hash string, limit int, cursor string) ([]Reaction, string, error) {
 	type ReactionsResponse struct {
 		Result struct {
@@ -52,7 +60,6 @@ func (r *ReactionService) GetReactionsByCastHash(hash string, limit int, cursor
 	if err != nil {
 		return nil, "", err
 	}
-	println(string(responseBytes))
 	var response ReactionsResponse
 	if err := json.Unmarshal(responseBytes, &response); err == nil {
 		if len(response.Errors) > 0 {
@@ -93,6 +100,7 @@ func (r *ReactionService) ReactToCast(hash string) (*Reaction, error) {
 			// TODO(ertan): Find a better solution to pass the errors here.
 			return nil, errors.New(response.Errors[0].Message)
 		}
+		r.cache.Delete(cache.KindCast, hash)
 		return response.Result.Reaction, nil
 	}
 	return nil, errors.New("Error reacting to cast")
@@ -121,6 +129,7 @@ func (r *ReactionService) UnreactToCast(hash string) error {
 	var response ReactionResponse
 	if err := json.Unmarshal(responseBytes, &response); err == nil {
 		if response.Result.Success {
+			r.cache.Delete(cache.KindCast, hash)
 			return nil
 		}
 	}
@@ -192,6 +201,7 @@ func (r *ReactionService) RecastCast(hash string) (string, error) {
 			// TODO(ertan): Find a better solution to pass the errors here.
 			return "", errors.New(response.Errors[0].Message)
 		}
+		r.cache.Delete(cache.KindCast, hash)
 		return response.Result.CastHash, nil
 	}
 	return "", errors.New("Error recasting cast")
@@ -220,13 +230,14 @@ func (r *ReactionService) UnrecastCast(hash string) error {
 	var response ReactionResponse
 	if err := json.Unmarshal(responseBytes, &response); err == nil {
 		if response.Result.Success {
+			r.cache.Delete(cache.KindCast, hash)
 			return nil
 		}
 	}
 	return errors.New("Error unrecasting cast")
 }
 
-func (r *ReactionService) GetUserReactions(fid uint64) ([]Reaction, string, error) {
+func (r *ReactionService) GetUserReactions(fid uint64, limit int, cursor string) ([]Reaction, string, error) {
 	type UserReactionsResponse struct {
 		Result struct {
 			Reactions []Reaction `json:"likes"`
@@ -241,6 +252,12 @@ func (r *ReactionService) GetUserReactions(fid uint64) ([]Reaction, string, erro
 	params := map[string]interface{}{
 		"fid": fid,
 	}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	if cursor != "" {
+		params["cursor"] = cursor
+	}
 	responseBytes, err := r.account.SendRequest("GET", "/v2/user-cast-likes", params, nil)
 	if err != nil {
 		return nil, "", err
@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DeadLetters stores deliveries that failed every attempt so they can be
+// replayed later.
+type DeadLetters interface {
+	Add(delivery *Delivery) error
+	List() ([]*Delivery, error)
+	Remove(id string) error
+}
+
+// DirDeadLetters keeps one JSON file per failed delivery in a directory that
+// only the owner can read.
+type DirDeadLetters struct {
+	dir string
+}
+
+func NewDirDeadLetters(dir string) (*DirDeadLetters, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DirDeadLetters{
+		dir: dir,
+	}, nil
+}
+
+func (d *DirDeadLetters) path(id string) string {
+	return filepath.Join(d.dir, id+".json")
+}
+
+func (d *DirDeadLetters) Add(delivery *Delivery) error {
+	data, err := json.MarshalIndent(delivery, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.path(delivery.Id), data, 0o600)
+}
+
+func (d *DirDeadLetters) List() ([]*Delivery, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []*Delivery
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var delivery Delivery
+		if err := json.Unmarshal(data, &delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (d *DirDeadLetters) Remove(id string) error {
+	err := os.Remove(d.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+type MemoryDeadLetters struct {
+	mu         sync.Mutex
+	deliveries map[string]*Delivery
+}
+
+func NewMemoryDeadLetters() *MemoryDeadLetters {
+	return &MemoryDeadLetters{
+		deliveries: make(map[string]*Delivery),
+	}
+}
+
+func (d *MemoryDeadLetters) Add(delivery *Delivery) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := *delivery
+	d.deliveries[delivery.Id] = &copied
+	return nil
+}
+
+func (d *MemoryDeadLetters) List() ([]*Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deliveries := make([]*Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		copied := *delivery
+		deliveries = append(deliveries, &copied)
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (d *MemoryDeadLetters) Remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.deliveries, id)
+	return nil
+}
+
+func sortDeliveries(deliveries []*Delivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Event.Timestamp < deliveries[j].Event.Timestamp
+	})
+}
@@ -0,0 +1,413 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/notifications"
+)
+
+const (
+	EventCastCreated = "cast.created"
+	// Notification events are "notification." followed by the kind,
+	// e.g. "notification.reply".
+	EventNotificationPrefix = "notification."
+)
+
+// Headers set on every webhook request. The signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" with the endpoint's secret.
+const (
+	HeaderEvent     = "X-Farcaster-Event"
+	HeaderDelivery  = "X-Farcaster-Delivery"
+	HeaderTimestamp = "X-Farcaster-Timestamp"
+	HeaderSignature = "X-Farcaster-Signature"
+)
+
+type Event struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp uint64          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Endpoint struct {
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+	// Events limits the event types sent to this endpoint. An entry ending
+	// in "." matches every type with that prefix. Empty means all events.
+	Events []string `json:"events"`
+}
+
+func (e *Endpoint) wants(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, want := range e.Events {
+		if want == eventType || (strings.HasSuffix(want, ".") && strings.HasPrefix(eventType, want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event on its way to an endpoint. Only the endpoint url is
+// kept so secrets never end up in the dead letters, the secret is looked up
+// in Options.Endpoints when the delivery is sent.
+type Delivery struct {
+	Id        string `json:"id"`
+	Endpoint  string `json:"endpoint"`
+	Event     Event  `json:"event"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+type Options struct {
+	Endpoints []Endpoint
+	// Fids whose new casts are relayed as cast.created events.
+	Fids []uint64
+	// Interval between polls. Defaults to 30 seconds.
+	Interval time.Duration
+	// SkipNotifications turns off relaying notifications.
+	SkipNotifications bool
+	// WatchOptions are passed to NotificationService.Watch.
+	WatchOptions *notifications.WatchOptions
+	// MaxAttempts per delivery before it is dead lettered. Defaults to 5.
+	MaxAttempts int
+	// RetryDelay is the wait after the first failed attempt, doubled on every
+	// retry. Defaults to a second.
+	RetryDelay time.Duration
+	// DeadLetters stores failed deliveries. Defaults to memory.
+	DeadLetters DeadLetters
+	HTTPClient  *http.Client
+	OnError     func(err error)
+}
+
+// Relay pushes notifications and new casts to webhooks.
+type Relay struct {
+	notifications *notifications.NotificationService
+	casts         *casts.CastService
+	options       Options
+	clock         func() time.Time
+}
+
+func (r *Relay) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock()
+}
+
+func NewRelay(notificationService *notifications.NotificationService, castService *casts.CastService, options *Options) (*Relay, error) {
+	r := &Relay{
+		notifications: notificationService,
+		casts:         castService,
+	}
+	if options != nil {
+		r.options = *options
+	}
+	if len(r.options.Endpoints) == 0 {
+		return nil, errors.New("no endpoints configured")
+	}
+	if len(r.options.Fids) > 0 && castService == nil {
+		return nil, errors.New("cast service is required to relay casts")
+	}
+	if !r.options.SkipNotifications && notificationService == nil {
+		return nil, errors.New("notification service is required to relay notifications")
+	}
+	if r.options.Interval <= 0 {
+		r.options.Interval = 30 * time.Second
+	}
+	if r.options.MaxAttempts <= 0 {
+		r.options.MaxAttempts = 5
+	}
+	if r.options.RetryDelay <= 0 {
+		r.options.RetryDelay = time.Second
+	}
+	if r.options.DeadLetters == nil {
+		r.options.DeadLetters = NewMemoryDeadLetters()
+	}
+	if r.options.HTTPClient == nil {
+		r.options.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return r, nil
+}
+
+// Run relays events until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	if !r.options.SkipNotifications {
+		watch, err := r.notifications.Watch(ctx, r.options.Interval, r.options.WatchOptions)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for notification := range watch {
+				event, err := notificationEvent(notification)
+				if err != nil {
+					r.report(err)
+					continue
+				}
+				r.Publish(ctx, event)
+			}
+		}()
+	}
+	if len(r.options.Fids) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.watchCasts(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func notificationEvent(n notifications.Notification) (Event, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Id:        "notification-" + n.Id,
+		Type:      EventNotificationPrefix + string(n.Kind()),
+		Timestamp: n.Timestamp,
+		Data:      data,
+	}, nil
+}
+
+// watchCasts polls the casts of every fid and publishes the ones newer than
+// the newest seen, paging back until it reaches that one. Casts that exist
+// when the relay starts are skipped. A failed page drops the whole poll of
+// that fid so the next one starts again from the same cast.
+func (r *Relay) watchCasts(ctx context.Context) {
+	newest := make(map[uint64]uint64)
+	for {
+	fids:
+		for _, fid := range r.options.Fids {
+			last, started := newest[fid]
+			var fresh []casts.Cast
+			cursor := ""
+			for {
+				list, next, err := r.casts.GetCastsByFid(fid, 0, cursor)
+				if err != nil {
+					r.report(fmt.Errorf("fetching casts of fid %d: %w", fid, err))
+					continue fids
+				}
+				reached := false
+				for _, cast := range list {
+					if cast.Timestamp <= last {
+						reached = true
+						break
+					}
+					fresh = append(fresh, cast)
+				}
+				// The first poll only needs the newest cast.
+				if !started || reached || next == "" || len(list) == 0 {
+					break
+				}
+				cursor = next
+			}
+			// The API returns the newest casts first, publish oldest first.
+			for i := len(fresh) - 1; i >= 0; i-- {
+				cast := fresh[i]
+				if started {
+					data, err := json.Marshal(cast)
+					if err != nil {
+						r.report(err)
+						continue
+					}
+					r.Publish(ctx, Event{Id: "cast-" + cast.Hash, Type: EventCastCreated, Timestamp: cast.Timestamp, Data: data})
+				}
+				if cast.Timestamp > last {
+					last = cast.Timestamp
+				}
+			}
+			newest[fid] = last
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.options.Interval):
+		}
+	}
+}
+
+// Publish delivers event to every endpoint that wants it. Deliveries that
+// fail every attempt go to the dead letters.
+func (r *Relay) Publish(ctx context.Context, event Event) {
+	for _, endpoint := range r.options.Endpoints {
+		if !endpoint.wants(event.Type) {
+			continue
+		}
+		delivery := &Delivery{
+			Id:       deliveryId(endpoint.Url, event.Id),
+			Endpoint: endpoint.Url,
+			Event:    event,
+		}
+		r.deliver(ctx, delivery)
+	}
+}
+
+func (r *Relay) deliver(ctx context.Context, delivery *Delivery) bool {
+	delay := r.options.RetryDelay
+	for attempt := 0; attempt < r.options.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				attempt = r.options.MaxAttempts
+				continue
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		delivery.Attempts++
+		err := r.send(ctx, delivery)
+		if err == nil {
+			return true
+		}
+		delivery.LastError = err.Error()
+	}
+	if err := r.options.DeadLetters.Add(delivery); err != nil {
+		r.report(err)
+	}
+	r.report(fmt.Errorf("delivery %s to %s failed: %s", delivery.Event.Id, delivery.Endpoint, delivery.LastError))
+	return false
+}
+
+func (r *Relay) endpoint(url string) *Endpoint {
+	for i := range r.options.Endpoints {
+		if r.options.Endpoints[i].Url == url {
+			return &r.options.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+func (r *Relay) send(ctx context.Context, delivery *Delivery) error {
+	endpoint := r.endpoint(delivery.Endpoint)
+	if endpoint == nil {
+		return fmt.Errorf("endpoint %s is no longer configured", delivery.Endpoint)
+	}
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(r.now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, delivery.Event.Type)
+	request.Header.Set(HeaderDelivery, delivery.Id)
+	request.Header.Set(HeaderTimestamp, timestamp)
+	if endpoint.Secret != "" {
+		request.Header.Set(HeaderSignature, "sha256="+Sign(endpoint.Secret, timestamp, body))
+	}
+	response, err := r.options.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with %s", response.Status)
+	}
+	return nil
+}
+
+// Replay retries every dead lettered delivery once and removes the ones that
+// succeed. It returns the number of deliveries that are still failing.
+func (r *Relay) Replay(ctx context.Context) (int, error) {
+	deliveries, err := r.options.DeadLetters.List()
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return failed, err
+		}
+		delivery.Attempts++
+		if err := r.send(ctx, delivery); err != nil {
+			delivery.LastError = err.Error()
+			if err := r.options.DeadLetters.Add(delivery); err != nil {
+				return failed, err
+			}
+			failed++
+			continue
+		}
+		if err := r.options.DeadLetters.Remove(delivery.Id); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// Sign returns the hex encoded signature of a webhook body. Receivers should
+// compute it from the timestamp header and the raw body and compare it with
+// hmac.Equal.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DefaultTolerance is the age Verify accepts when no tolerance is given.
+const DefaultTolerance = 5 * time.Minute
+
+// Verify checks the signature headers of a webhook request against its body.
+// Requests whose timestamp is further than tolerance from now are rejected
+// so captured requests can't be replayed later. A zero tolerance uses
+// DefaultTolerance.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) bool {
+	return verifyAt(secret, header, body, tolerance, time.Now())
+}
+
+func verifyAt(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) bool {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	signature := header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	timestamp := header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	expected := Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected))
+}
+
+func deliveryId(url, eventId string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + eventId))
+	return hex.EncodeToString(sum[:12])
+}
+
+func (r *Relay) report(err error) {
+	if r.options.OnError != nil {
+		r.options.OnError(err)
+	}
+}
@@ -0,0 +1,256 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+func TestPublishSignsRetriesAndReplays(t *testing.T) {
+	var mu sync.Mutex
+	failing := true
+	secret := "secret"
+	var received []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if !verifyAt(secret, r.Header, body, time.Minute, time.Unix(1000, 0)) {
+			t.Errorf("Expected a valid signature for %s", body)
+		}
+		received = append(received, r)
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	deadLetters := NewMemoryDeadLetters()
+	r, err := NewRelay(nil, nil, &Options{
+		Endpoints: []Endpoint{
+			{Url: server.URL, Secret: "secret", Events: []string{"notification."}},
+			{Url: server.URL + "/casts", Secret: "secret", Events: []string{EventCastCreated}},
+		},
+		SkipNotifications: true,
+		MaxAttempts:       3,
+		RetryDelay:        time.Millisecond,
+		DeadLetters:       deadLetters,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.clock = func() time.Time { return time.Unix(1000, 0) }
+
+	event := Event{Id: "n1", Type: EventNotificationPrefix + "reply", Timestamp: 1, Data: json.RawMessage(`{}`)}
+	r.Publish(context.Background(), event)
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 attempts to the notification endpoint, got %d", len(received))
+	}
+	if received[0].Header.Get(HeaderTimestamp) != "1000" || received[0].Header.Get(HeaderEvent) != event.Type {
+		t.Errorf("Expected timestamp and event headers, got %v", received[0].Header)
+	}
+	dead, _ := deadLetters.List()
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("Expected one dead letter after 3 attempts, got %+v", dead)
+	}
+
+	// Replays are signed with the current secret of the endpoint.
+	mu.Lock()
+	failing = false
+	secret = "rotated"
+	mu.Unlock()
+	r.options.Endpoints[0].Secret = "rotated"
+	failed, err := r.Replay(context.Background())
+	if err != nil || failed != 0 {
+		t.Fatalf("Expected replay to succeed, got %d failing and %v", failed, err)
+	}
+	if dead, _ := deadLetters.List(); len(dead) != 0 {
+		t.Errorf("Expected no dead letters after replay, got %d", len(dead))
+	}
+	if received[3].Header.Get(HeaderDelivery) != received[0].Header.Get(HeaderDelivery) {
+		t.Errorf("Expected the replay to keep the delivery id")
+	}
+}
+
+func TestDirDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	deadLetters, err := NewDirDeadLetters(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*Delivery{
+		{Id: "b", Endpoint: "http://example.com", Event: Event{Timestamp: 2}},
+		{Id: "a", Event: Event{Timestamp: 1}},
+	} {
+		if err := deadLetters.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := deadLetters.List()
+	if err != nil || len(list) != 2 || list[0].Id != "a" || list[1].Endpoint != "http://example.com" {
+		t.Fatalf("Expected a then b, got %+v, %v", list, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "b.json")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected dead letters to be private, got %v", info.Mode())
+	}
+	if err := deadLetters.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := deadLetters.Remove("a"); err != nil {
+		t.Errorf("Expected removing a missing delivery to succeed, got %v", err)
+	}
+	if list, _ := deadLetters.List(); len(list) != 1 {
+		t.Errorf("Expected one delivery left, got %d", len(list))
+	}
+}
+
+func TestVerifyRejectsStaleRequests(t *testing.T) {
+	body := []byte(`{}`)
+	header := http.Header{}
+	header.Set(HeaderTimestamp, "1000")
+	header.Set(HeaderSignature, "sha256="+Sign("secret", "1000", body))
+	if !verifyAt("secret", header, body, time.Minute, time.Unix(1030, 0)) {
+		t.Errorf("Expected a fresh request to verify")
+	}
+	if verifyAt("secret", header, body, time.Minute, time.Unix(1100, 0)) {
+		t.Errorf("Expected a request older than the tolerance to be rejected")
+	}
+	if verifyAt("secret", header, body, 0, time.Unix(1000, 0).Add(DefaultTolerance+time.Second)) {
+		t.Errorf("Expected a zero tolerance to use the default")
+	}
+	if Verify("secret", header, body, time.Minute) {
+		t.Errorf("Expected a captured request to be rejected now")
+	}
+}
+
+func TestWatchCastsPagesThroughBursts(t *testing.T) {
+	var polls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		if cursor == "" && atomic.AddInt32(&polls, 1) == 1 {
+			fmt.Fprint(w, `{"result":{"casts":[{"hash":"0x1","timestamp":1}]}}`)
+			return
+		}
+		// Five new casts since the first poll, two per page.
+		timestamps := []int{6, 5, 4, 3, 2, 1}
+		start, _ := strconv.Atoi(cursor)
+		var items []string
+		for i := start; i < len(timestamps) && i < start+2; i++ {
+			items = append(items, fmt.Sprintf(`{"hash":"0x%d","timestamp":%d}`, timestamps[i], timestamps[i]))
+		}
+		next := ""
+		if start+2 < len(timestamps) {
+			next = strconv.Itoa(start + 2)
+		}
+		fmt.Fprintf(w, `{"result":{"casts":[%s]},"next":{"cursor":"%s"}}`, strings.Join(items, ","), next)
+	}))
+	defer api.Close()
+	received := make(chan uint64, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event.Timestamp
+	}))
+	defer webhook.Close()
+
+	castService := casts.NewCastService(account.NewAccountService(api.URL, ""), nil)
+	r, err := NewRelay(nil, castService, &Options{
+		Endpoints:         []Endpoint{{Url: webhook.URL, Secret: "secret"}},
+		Fids:              []uint64{1},
+		Interval:          time.Millisecond,
+		SkipNotifications: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+	for expected := uint64(2); expected <= 6; expected++ {
+		select {
+		case timestamp := <-received:
+			if timestamp != expected {
+				t.Fatalf("Expected cast %d, got %d", expected, timestamp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for cast %d", expected)
+		}
+	}
+}
+
+func TestWatchCastsRetriesFailedPolls(t *testing.T) {
+	var polls, olderPages int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") != "" {
+			// The older page of the burst fails once.
+			if atomic.AddInt32(&olderPages, 1) == 1 {
+				fmt.Fprint(w, `{"errors":[{"message":"rate limited"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":{"casts":[{"hash":"0x1","timestamp":1}]}}`)
+			return
+		}
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			fmt.Fprint(w, `{"errors":[{"message":"unavailable"}]}`)
+		case 2:
+			fmt.Fprint(w, `{"result":{"casts":[{"hash":"0x1","timestamp":1}]}}`)
+		default:
+			fmt.Fprint(w, `{"result":{"casts":[{"hash":"0x3","timestamp":3},{"hash":"0x2","timestamp":2}]},"next":{"cursor":"2"}}`)
+		}
+	}))
+	defer api.Close()
+	received := make(chan uint64, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event.Timestamp
+	}))
+	defer webhook.Close()
+
+	castService := casts.NewCastService(account.NewAccountService(api.URL, ""), nil)
+	r, err := NewRelay(nil, castService, &Options{
+		Endpoints:         []Endpoint{{Url: webhook.URL}},
+		Fids:              []uint64{1},
+		Interval:          time.Millisecond,
+		SkipNotifications: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+	for expected := uint64(2); expected <= 3; expected++ {
+		select {
+		case timestamp := <-received:
+			if timestamp != expected {
+				t.Fatalf("Expected cast %d, got %d", expected, timestamp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for cast %d", expected)
+		}
+	}
+	for atomic.LoadInt32(&polls) < 6 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case timestamp := <-received:
+		t.Errorf("Expected no more casts, got %d", timestamp)
+	default:
+	}
+}
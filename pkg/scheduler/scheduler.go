@@ -0,0 +1,380 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPublished Status = "published"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+type ScheduledCast struct {
+	Id         string    `json:"id"`
+	Text       string    `json:"text"`
+	ParentFid  uint64    `json:"parentFid,omitempty"`
+	ParentHash string    `json:"parentHash,omitempty"`
+	PublishAt  time.Time `json:"publishAt"`
+	Status     Status    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"lastError,omitempty"`
+	CastHash   string    `json:"castHash,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// FinishedAt is when the cast was published, failed or canceled.
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+func (s *ScheduledCast) IsReply() bool {
+	return s.ParentHash != ""
+}
+
+func (s *ScheduledCast) finished() bool {
+	return s.Status != StatusPending
+}
+
+// Publisher is the part of casts.CastService the scheduler needs.
+type Publisher interface {
+	PublishCast(text string) (*casts.Cast, error)
+	PublishReplyCast(text string, fid uint64, hash string) (*casts.Cast, error)
+}
+
+type Options struct {
+	// PollInterval is how often the queue is checked for due casts. Defaults to a second.
+	PollInterval time.Duration
+	// MaxAttempts is the number of tries before a cast is marked as failed. Defaults to 3.
+	MaxAttempts int
+	// RetryDelay is the wait after the first failure, doubled on every retry.
+	// Defaults to 30 seconds.
+	RetryDelay time.Duration
+	// OnStatus is called with a copy of the cast whenever its status or attempts change.
+	OnStatus func(cast ScheduledCast)
+	// OnError is called with errors that don't stop Run, like a cast that was
+	// published after it was removed from the queue.
+	OnError func(id string, err error)
+	// Retention is how long published, failed and canceled casts are kept in
+	// the queue. Defaults to a week.
+	Retention time.Duration
+}
+
+type Scheduler struct {
+	publisher Publisher
+	store     Store
+	options   Options
+	mu        sync.Mutex
+	queue     []*ScheduledCast
+	clock     func() time.Time
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+// NewScheduler loads the queue from store. Pass a *casts.CastService as publisher.
+func NewScheduler(publisher Publisher, store Store, options *Options) (*Scheduler, error) {
+	if publisher == nil {
+		return nil, errors.New("publisher is nil")
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	s := &Scheduler{
+		publisher: publisher,
+		store:     store,
+	}
+	if options != nil {
+		s.options = *options
+	}
+	if s.options.PollInterval <= 0 {
+		s.options.PollInterval = time.Second
+	}
+	if s.options.MaxAttempts <= 0 {
+		s.options.MaxAttempts = 3
+	}
+	if s.options.RetryDelay <= 0 {
+		s.options.RetryDelay = 30 * time.Second
+	}
+	if s.options.Retention <= 0 {
+		s.options.Retention = 7 * 24 * time.Hour
+	}
+	queue, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.queue = queue
+	return s, nil
+}
+
+func (s *Scheduler) Schedule(text string, publishAt time.Time) (*ScheduledCast, error) {
+	return s.add(&ScheduledCast{Text: text, PublishAt: publishAt})
+}
+
+func (s *Scheduler) ScheduleReply(text string, fid uint64, hash string, publishAt time.Time) (*ScheduledCast, error) {
+	if hash == "" {
+		return nil, errors.New("parent hash is empty")
+	}
+	return s.add(&ScheduledCast{Text: text, ParentFid: fid, ParentHash: hash, PublishAt: publishAt})
+}
+
+func (s *Scheduler) add(cast *ScheduledCast) (*ScheduledCast, error) {
+	if cast.Text == "" {
+		return nil, errors.New("text is empty")
+	}
+	id, err := newId()
+	if err != nil {
+		return nil, err
+	}
+	cast.Id = id
+	cast.Status = StatusPending
+	cast.CreatedAt = s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, cast)
+	if err := s.save(); err != nil {
+		s.queue = s.queue[:len(s.queue)-1]
+		return nil, err
+	}
+	copied := *cast
+	return &copied, nil
+}
+
+// List returns copies of every cast in the queue ordered by publish time.
+func (s *Scheduler) List() []ScheduledCast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]ScheduledCast, len(s.queue))
+	for i, cast := range s.queue {
+		list[i] = *cast
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].PublishAt.Before(list[j].PublishAt)
+	})
+	return list
+}
+
+func (s *Scheduler) Get(id string) (*ScheduledCast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cast, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *cast
+	return &copied, nil
+}
+
+func (s *Scheduler) Cancel(id string) error {
+	return s.update(id, func(cast *ScheduledCast) {
+		cast.Status = StatusCanceled
+		cast.FinishedAt = s.now()
+	})
+}
+
+// Remove drops a cast from the queue whatever its status. A cast removed
+// while it is being published still goes out, its hash is passed to OnError.
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, cast := range s.queue {
+		if cast.Id != id {
+			continue
+		}
+		previous := s.queue
+		s.queue = append(append([]*ScheduledCast{}, s.queue[:i]...), s.queue[i+1:]...)
+		if err := s.save(); err != nil {
+			s.queue = previous
+			return err
+		}
+		return nil
+	}
+	return errors.New("scheduled cast not found")
+}
+
+// Reschedule moves a pending, canceled or failed cast to a new publish time
+// and resets its attempts.
+func (s *Scheduler) Reschedule(id string, publishAt time.Time) error {
+	return s.update(id, func(cast *ScheduledCast) {
+		cast.Status = StatusPending
+		cast.PublishAt = publishAt
+		cast.Attempts = 0
+		cast.LastError = ""
+		cast.FinishedAt = time.Time{}
+	})
+}
+
+func (s *Scheduler) update(id string, fn func(cast *ScheduledCast)) error {
+	s.mu.Lock()
+	cast, err := s.find(id)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if cast.Status == StatusPublished {
+		s.mu.Unlock()
+		return errors.New("cast is already published")
+	}
+	previous := *cast
+	fn(cast)
+	if err := s.save(); err != nil {
+		*cast = previous
+		s.mu.Unlock()
+		return err
+	}
+	copied := *cast
+	s.mu.Unlock()
+	s.notify(copied)
+	return nil
+}
+
+func (s *Scheduler) find(id string) (*ScheduledCast, error) {
+	for _, cast := range s.queue {
+		if cast.Id == id {
+			return cast, nil
+		}
+	}
+	return nil, errors.New("scheduled cast not found")
+}
+
+// Run publishes due casts until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.publishDue(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue publishes every pending cast whose time has come. Only store
+// errors are returned, publish errors are recorded on the cast.
+func (s *Scheduler) publishDue() error {
+	for _, cast := range s.due() {
+		var published *casts.Cast
+		var err error
+		if cast.IsReply() {
+			published, err = s.publisher.PublishReplyCast(cast.Text, cast.ParentFid, cast.ParentHash)
+		} else {
+			published, err = s.publisher.PublishCast(cast.Text)
+		}
+		if err == nil && published == nil {
+			err = errors.New("empty publish response")
+		}
+
+		s.mu.Lock()
+		current, findErr := s.find(cast.Id)
+		if findErr != nil {
+			s.mu.Unlock()
+			if err == nil {
+				s.reportError(cast.Id, fmt.Errorf("cast %s was published after it was removed from the queue", published.Hash))
+			}
+			continue
+		}
+		if current.Status != StatusPending && err != nil {
+			// Canceled while publishing and nothing went out, leave it canceled.
+			s.mu.Unlock()
+			continue
+		}
+		// A cast canceled while publishing is live anyway, so it is recorded
+		// as published like any other.
+		current.Attempts++
+		if err == nil {
+			current.Status = StatusPublished
+			current.CastHash = published.Hash
+			current.LastError = ""
+			current.FinishedAt = s.now()
+		} else {
+			current.LastError = err.Error()
+			if current.Attempts >= s.options.MaxAttempts {
+				current.Status = StatusFailed
+				current.FinishedAt = s.now()
+			} else {
+				current.PublishAt = s.now().Add(s.options.RetryDelay << (current.Attempts - 1))
+			}
+		}
+		saveErr := s.save()
+		copied := *current
+		s.mu.Unlock()
+		if saveErr != nil {
+			return saveErr
+		}
+		s.notify(copied)
+	}
+	return nil
+}
+
+// save prunes the casts that finished more than Retention ago and stores the
+// queue. Callers hold s.mu.
+func (s *Scheduler) save() error {
+	cutoff := s.now().Add(-s.options.Retention)
+	kept := s.queue[:0:0]
+	for _, cast := range s.queue {
+		finishedAt := cast.FinishedAt
+		if finishedAt.IsZero() {
+			// Queues saved before FinishedAt existed.
+			finishedAt = cast.PublishAt
+		}
+		if cast.finished() && finishedAt.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, cast)
+	}
+	s.queue = kept
+	return s.store.Save(s.queue)
+}
+
+func (s *Scheduler) due() []ScheduledCast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var due []ScheduledCast
+	for _, cast := range s.queue {
+		if cast.Status == StatusPending && !cast.PublishAt.After(now) {
+			due = append(due, *cast)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].PublishAt.Before(due[j].PublishAt)
+	})
+	return due
+}
+
+func (s *Scheduler) notify(cast ScheduledCast) {
+	if s.options.OnStatus != nil {
+		s.options.OnStatus(cast)
+	}
+}
+
+func (s *Scheduler) reportError(id string, err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(id, err)
+	}
+}
+
+func newId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+type fakePublisher struct {
+	failures int
+	texts    []string
+	// onPublish runs while a cast is being published.
+	onPublish func()
+}
+
+func (p *fakePublisher) PublishCast(text string) (*casts.Cast, error) {
+	if p.onPublish != nil {
+		p.onPublish()
+	}
+	if p.failures > 0 {
+		p.failures--
+		return nil, errors.New("server error")
+	}
+	p.texts = append(p.texts, text)
+	return &casts.Cast{Hash: "0xcast", Text: text}, nil
+}
+
+func (p *fakePublisher) PublishReplyCast(text string, fid uint64, hash string) (*casts.Cast, error) {
+	return p.PublishCast(text)
+}
+
+func TestSchedulerPublishesWithRetries(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	publisher := &fakePublisher{failures: 1}
+	store := NewFileStore(filepath.Join(t.TempDir(), "queue.json"))
+	var statuses []Status
+	scheduler, err := NewScheduler(publisher, store, &Options{
+		RetryDelay: time.Minute,
+		OnStatus: func(cast ScheduledCast) {
+			statuses = append(statuses, cast.Status)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.clock = func() time.Time { return now }
+
+	later, _ := scheduler.Schedule("later", now.Add(time.Hour))
+	soon, _ := scheduler.Schedule("soon", now.Add(time.Minute))
+	canceled, _ := scheduler.Schedule("canceled", now)
+	if err := scheduler.Cancel(canceled.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	scheduler.publishDue()
+	if cast, _ := scheduler.Get(soon.Id); cast.Status != StatusPending || cast.Attempts != 1 {
+		t.Errorf("Expected a pending retry after the first failure, got %+v", cast)
+	}
+	now = now.Add(time.Minute)
+	scheduler.publishDue()
+	if cast, _ := scheduler.Get(soon.Id); cast.Status != StatusPublished || cast.CastHash != "0xcast" {
+		t.Errorf("Expected the retry to publish, got %+v", cast)
+	}
+	if len(publisher.texts) != 1 || publisher.texts[0] != "soon" {
+		t.Errorf("Expected only 'soon' to be published, got %v", publisher.texts)
+	}
+
+	// The queue survives a restart.
+	reloaded, err := NewScheduler(publisher, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.List()
+	if len(list) != 3 || list[2].Id != later.Id || list[2].Status != StatusPending {
+		t.Errorf("Expected the reloaded queue to keep 'later' pending, got %+v", list)
+	}
+	expected := []Status{StatusCanceled, StatusPending, StatusPublished}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected statuses %v, got %v", expected, statuses)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("Expected statuses %v, got %v", expected, statuses)
+		}
+	}
+}
+
+func TestSchedulerCancelDuringPublish(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	publisher := &fakePublisher{}
+	store := NewFileStore(filepath.Join(t.TempDir(), "queue.json"))
+	var statuses []Status
+	var errs []error
+	scheduler, err := NewScheduler(publisher, store, &Options{
+		OnStatus: func(cast ScheduledCast) {
+			statuses = append(statuses, cast.Status)
+		},
+		OnError: func(id string, err error) {
+			errs = append(errs, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.clock = func() time.Time { return now }
+
+	cast, _ := scheduler.Schedule("racy", now)
+	publisher.onPublish = func() {
+		if err := scheduler.Cancel(cast.Id); err != nil {
+			t.Error(err)
+		}
+	}
+	scheduler.publishDue()
+	reloaded, err := NewScheduler(publisher, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Get(cast.Id); got.Status != StatusPublished || got.CastHash != "0xcast" {
+		t.Errorf("Expected the live cast to be stored as published, got %+v", got)
+	}
+	if len(statuses) != 2 || statuses[0] != StatusCanceled || statuses[1] != StatusPublished {
+		t.Errorf("Expected canceled then published, got %v", statuses)
+	}
+
+	// A cast removed from the queue while publishing is reported.
+	removed, _ := scheduler.Schedule("removed", now)
+	publisher.onPublish = func() {
+		if err := scheduler.Remove(removed.Id); err != nil {
+			t.Error(err)
+		}
+	}
+	scheduler.publishDue()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "0xcast") {
+		t.Errorf("Expected the orphaned hash of %s to be reported, got %v", removed.Id, errs)
+	}
+}
+
+func TestSchedulerPrunesFinishedCasts(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	scheduler, err := NewScheduler(&fakePublisher{}, store, &Options{Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.clock = func() time.Time { return now }
+
+	published, _ := scheduler.Schedule("published", now)
+	canceled, _ := scheduler.Schedule("canceled", now.Add(time.Minute))
+	scheduler.Cancel(canceled.Id)
+	scheduler.publishDue()
+	pending, _ := scheduler.Schedule("pending", now.Add(3*time.Hour))
+	if len(scheduler.List()) != 3 {
+		t.Fatalf("Expected finished casts to be kept within the retention, got %+v", scheduler.List())
+	}
+
+	now = now.Add(2 * time.Hour)
+	removed, _ := scheduler.Schedule("removed", now.Add(time.Hour))
+	if err := scheduler.Remove(removed.Id); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := store.Load()
+	if len(list) != 1 || list[0].Id != pending.Id {
+		t.Errorf("Expected only %s to be stored, got %+v", pending.Id, list)
+	}
+	if _, err := scheduler.Get(published.Id); err == nil {
+		t.Errorf("Expected the published cast to be pruned")
+	}
+	if err := scheduler.Remove(removed.Id); err == nil {
+		t.Errorf("Expected removing a missing cast to fail")
+	}
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the queue between runs. Save is called with the full queue
+// after every change.
+type Store interface {
+	Load() ([]*ScheduledCast, error)
+	Save(queue []*ScheduledCast) error
+}
+
+type MemoryStore struct {
+	mu    sync.Mutex
+	queue []*ScheduledCast
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() ([]*ScheduledCast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyQueue(s.queue), nil
+}
+
+func (s *MemoryStore) Save(queue []*ScheduledCast) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = copyQueue(queue)
+	return nil
+}
+
+// FileStore keeps the queue in a JSON file.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (s *FileStore) Load() ([]*ScheduledCast, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queue []*ScheduledCast
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (s *FileStore) Save(queue []*ScheduledCast) error {
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated queue.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func copyQueue(queue []*ScheduledCast) []*ScheduledCast {
+	copied := make([]*ScheduledCast, len(queue))
+	for i, item := range queue {
+		c := *item
+		copied[i] = &c
+	}
+	return copied
+}
@@ -0,0 +1,478 @@
+package search
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+)
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type document struct {
+	Cast   casts.Cast
+	Length int
+}
+
+// Index is an in-memory inverted index over casts. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string][]int // term -> cast hash -> positions
+	total    int                         // sum of document lengths
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// Tokenize lowercases text and splits it into words. Mentions and hashtags
+// are indexed without their prefix.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.docs)
+}
+
+// Add indexes casts, replacing the ones that are already in the index.
+func (i *Index) Add(list ...casts.Cast) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, cast := range list {
+		if cast.Hash == "" {
+			continue
+		}
+		i.remove(cast.Hash)
+		tokens := Tokenize(cast.Text)
+		i.docs[cast.Hash] = &document{Cast: cast, Length: len(tokens)}
+		i.total += len(tokens)
+		for position, token := range tokens {
+			postings, ok := i.postings[token]
+			if !ok {
+				postings = make(map[string][]int)
+				i.postings[token] = postings
+			}
+			postings[cast.Hash] = append(postings[cast.Hash], position)
+		}
+	}
+}
+
+func (i *Index) Remove(hash string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(hash)
+}
+
+func (i *Index) remove(hash string) {
+	doc, ok := i.docs[hash]
+	if !ok {
+		return
+	}
+	for _, token := range Tokenize(doc.Cast.Text) {
+		if postings, ok := i.postings[token]; ok {
+			delete(postings, hash)
+			if len(postings) == 0 {
+				delete(i.postings, token)
+			}
+		}
+	}
+	i.total -= doc.Length
+	delete(i.docs, hash)
+}
+
+// AddJSONL indexes casts from r, one JSON object per line. Lines can either be
+// casts or archive records with the cast under a "cast" key, so files written
+// by the archive package can be indexed directly.
+func (i *Index) AddJSONL(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var record struct {
+			Cast   *casts.Cast  `json:"cast"`
+			Thread []casts.Cast `json:"thread"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return count, err
+		}
+		if record.Cast != nil {
+			i.Add(*record.Cast)
+			i.Add(record.Thread...)
+			count++
+			continue
+		}
+		var cast casts.Cast
+		if err := json.Unmarshal(line, &cast); err != nil {
+			return count, err
+		}
+		i.Add(cast)
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// AddCastsByFid fetches every cast of fid from the API and indexes it.
+func (i *Index) AddCastsByFid(service *casts.CastService, fid uint64) (int, error) {
+	count := 0
+	cursor := ""
+	for {
+		page, next, err := service.GetCastsByFid(fid, 0, cursor)
+		if err != nil {
+			return count, err
+		}
+		i.Add(page...)
+		count += len(page)
+		if next == "" || len(page) == 0 {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+type Query struct {
+	// Text is parsed by ParseQuery and combined with the other fields. It
+	// supports "quoted phrases", -excluded terms, the from:, fid:, after:
+	// and before: filters and -from: to exclude an author.
+	Text string
+	// Terms must all appear in a cast. Terms, Phrases and Exclude are
+	// tokenized like the casts, so case and punctuation don't matter.
+	Terms []string
+	// Phrases must appear in a cast with their words in order.
+	Phrases [][]string
+	// Exclude drops casts containing any of these terms.
+	Exclude []string
+	// Author matches the author's username, Fid the author's fid.
+	Author string
+	Fid    uint64
+	// ExcludeAuthors drops casts by any of these usernames.
+	ExcludeAuthors []string
+	// After and Before bound the cast timestamp.
+	After  time.Time
+	Before time.Time
+	// Limit caps the number of results, 0 means no limit.
+	Limit int
+}
+
+type Result struct {
+	Cast  casts.Cast
+	Score float64
+}
+
+// Search returns the casts matching q, best match first. Casts with equal
+// scores are ordered newest first.
+func (i *Index) Search(q Query) ([]Result, error) {
+	if q.Text != "" {
+		parsed, err := ParseQuery(q.Text)
+		if err != nil {
+			return nil, err
+		}
+		parsed.Limit = q.Limit
+		q = mergeQuery(parsed, q)
+	}
+	q = normalizeQuery(q)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	// Every distinct term is required and scored once, whether it comes from
+	// Terms or a phrase.
+	var required []string
+	seen := make(map[string]bool)
+	for _, terms := range append([][]string{q.Terms}, q.Phrases...) {
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				required = append(required, term)
+			}
+		}
+	}
+	candidates := i.candidates(required)
+	var results []Result
+	for hash := range candidates {
+		doc := i.docs[hash]
+		if !i.matches(doc, hash, q) {
+			continue
+		}
+		results = append(results, Result{Cast: doc.Cast, Score: i.score(hash, doc, required)})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Cast.Timestamp > results[b].Cast.Timestamp
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func mergeQuery(parsed, q Query) Query {
+	parsed.Terms = append(parsed.Terms, q.Terms...)
+	parsed.Phrases = append(parsed.Phrases, q.Phrases...)
+	parsed.Exclude = append(parsed.Exclude, q.Exclude...)
+	parsed.ExcludeAuthors = append(parsed.ExcludeAuthors, q.ExcludeAuthors...)
+	if q.Author != "" {
+		parsed.Author = q.Author
+	}
+	if q.Fid != 0 {
+		parsed.Fid = q.Fid
+	}
+	if !q.After.IsZero() {
+		parsed.After = q.After
+	}
+	if !q.Before.IsZero() {
+		parsed.Before = q.Before
+	}
+	return parsed
+}
+
+// normalizeQuery tokenizes the terms of q the same way casts are indexed.
+func normalizeQuery(q Query) Query {
+	q.Terms = tokenizeAll(q.Terms)
+	q.Exclude = tokenizeAll(q.Exclude)
+	phrases := make([][]string, 0, len(q.Phrases))
+	for _, phrase := range q.Phrases {
+		if tokens := tokenizeAll(phrase); len(tokens) > 0 {
+			phrases = append(phrases, tokens)
+		}
+	}
+	q.Phrases = phrases
+	return q
+}
+
+func tokenizeAll(terms []string) []string {
+	var tokens []string
+	for _, term := range terms {
+		tokens = append(tokens, Tokenize(term)...)
+	}
+	return tokens
+}
+
+// candidates returns the casts containing every term, or every cast if there
+// are no terms so filter only queries work.
+func (i *Index) candidates(terms []string) map[string]bool {
+	candidates := make(map[string]bool)
+	if len(terms) == 0 {
+		for hash := range i.docs {
+			candidates[hash] = true
+		}
+		return candidates
+	}
+	// Start from the rarest term to keep the intersection small.
+	sorted := append([]string{}, terms...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return len(i.postings[sorted[a]]) < len(i.postings[sorted[b]])
+	})
+	for hash := range i.postings[sorted[0]] {
+		candidates[hash] = true
+	}
+	for _, term := range sorted[1:] {
+		postings := i.postings[term]
+		for hash := range candidates {
+			if _, ok := postings[hash]; !ok {
+				delete(candidates, hash)
+			}
+		}
+	}
+	return candidates
+}
+
+func (i *Index) matches(doc *document, hash string, q Query) bool {
+	cast := doc.Cast
+	if q.Author != "" && (cast.Author == nil || !strings.EqualFold(cast.Author.Username, q.Author)) {
+		return false
+	}
+	if q.Fid != 0 && (cast.Author == nil || cast.Author.Fid != q.Fid) {
+		return false
+	}
+	for _, author := range q.ExcludeAuthors {
+		if cast.Author != nil && strings.EqualFold(cast.Author.Username, author) {
+			return false
+		}
+	}
+	timestamp := time.UnixMilli(int64(cast.Timestamp))
+	if !q.After.IsZero() && timestamp.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !timestamp.Before(q.Before) {
+		return false
+	}
+	for _, term := range q.Exclude {
+		if _, ok := i.postings[term][hash]; ok {
+			return false
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if !i.hasPhrase(hash, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Index) hasPhrase(hash string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return true
+	}
+	for _, start := range i.postings[phrase[0]][hash] {
+		found := true
+		for offset, term := range phrase[1:] {
+			if !containsInt(i.postings[term][hash], start+offset+1) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	// Positions are appended in order, so the list is sorted.
+	n := sort.SearchInts(list, value)
+	return n < len(list) && list[n] == value
+}
+
+func (i *Index) score(hash string, doc *document, terms []string) float64 {
+	if len(terms) == 0 || len(i.docs) == 0 {
+		return 0
+	}
+	n := float64(len(i.docs))
+	avg := float64(i.total) / n
+	score := 0.0
+	for _, term := range terms {
+		postings := i.postings[term]
+		df := float64(len(postings))
+		tf := float64(len(postings[hash]))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.Length)/avg))
+	}
+	return score
+}
+
+// ParseQuery parses the query syntax described on Query.Text.
+func ParseQuery(text string) (Query, error) {
+	var q Query
+	for _, field := range splitQuery(text) {
+		if strings.HasPrefix(field, `"`) {
+			if phrase := Tokenize(strings.Trim(field, `"`)); len(phrase) > 0 {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			excluded := field[1:]
+			if key, value, ok := strings.Cut(excluded, ":"); ok && value != "" && strings.EqualFold(key, "from") {
+				q.ExcludeAuthors = append(q.ExcludeAuthors, strings.TrimPrefix(value, "@"))
+				continue
+			}
+			q.Exclude = append(q.Exclude, Tokenize(excluded)...)
+			continue
+		}
+		if key, value, ok := strings.Cut(field, ":"); ok && value != "" {
+			var err error
+			switch strings.ToLower(key) {
+			case "from":
+				q.Author = strings.TrimPrefix(value, "@")
+				continue
+			case "fid":
+				var fid uint64
+				fid, err = strconv.ParseUint(value, 10, 64)
+				q.Fid = fid
+			case "after":
+				q.After, err = time.Parse("2006-01-02", value)
+			case "before":
+				q.Before, err = time.Parse("2006-01-02", value)
+			default:
+				q.Terms = append(q.Terms, Tokenize(field)...)
+				continue
+			}
+			if err != nil {
+				return Query{}, errors.New("invalid " + key + " filter: " + value)
+			}
+			continue
+		}
+		q.Terms = append(q.Terms, Tokenize(field)...)
+	}
+	return q, nil
+}
+
+// splitQuery splits on spaces but keeps quoted phrases together.
+func splitQuery(text string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			current.WriteRune(r)
+			if quoted {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// Save writes the indexed casts to w. Postings are rebuilt on Load, which
+// keeps the file small and the format independent of the index layout.
+func (i *Index) Save(w io.Writer) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	list := make([]casts.Cast, 0, len(i.docs))
+	for _, doc := range i.docs {
+		list = append(list, doc.Cast)
+	}
+	return gob.NewEncoder(w).Encode(list)
+}
+
+func Load(r io.Reader) (*Index, error) {
+	var list []casts.Cast
+	if err := gob.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	index := NewIndex()
+	index.Add(list...)
+	return index, nil
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/casts"
+	"github.com/ertan/go-farcaster/pkg/users"
+)
+
+func testIndex() *Index {
+	day := func(d int) uint64 {
+		return uint64(time.Date(2023, time.January, d, 0, 0, 0, 0, time.UTC).UnixMilli())
+	}
+	index := NewIndex()
+	index.Add(
+		casts.Cast{Hash: "0x1", Text: "Shipping the new client today", Timestamp: day(1), Author: &users.User{Fid: 1, Username: "alice"}},
+		casts.Cast{Hash: "0x2", Text: "The client is new, shipping soon. Client client!", Timestamp: day(2), Author: &users.User{Fid: 2, Username: "bob"}},
+		casts.Cast{Hash: "0x3", Text: "gm everyone", Timestamp: day(3), Author: &users.User{Fid: 1, Username: "alice"}},
+	)
+	return index
+}
+
+func hashes(results []Result) []string {
+	var list []string
+	for _, result := range results {
+		list = append(list, result.Cast.Hash)
+	}
+	return list
+}
+
+func TestSearch(t *testing.T) {
+	index := testIndex()
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"client", []string{"0x2", "0x1"}},
+		{`"new client"`, []string{"0x1"}},
+		{"client -soon", []string{"0x1"}},
+		{"from:@alice", []string{"0x3", "0x1"}},
+		{"fid:2 shipping", []string{"0x2"}},
+		{"after:2023-01-02 before:2023-01-03", []string{"0x2"}},
+		{"client -from:@bob", []string{"0x1"}},
+		{"client -soon.", []string{"0x1"}},
+		{"-fid:2 -today", []string{"0x3", "0x2"}},
+		{"missing", nil},
+	}
+	for _, test := range tests {
+		results, err := index.Search(Query{Text: test.query})
+		if err != nil {
+			t.Fatalf("%s: %s", test.query, err)
+		}
+		if got := hashes(results); len(got) != len(test.expected) || (len(got) > 0 && got[0] != test.expected[0]) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+	if _, err := index.Search(Query{Text: "after:yesterday"}); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+
+	// Fields are tokenized like the text.
+	results, err := index.Search(Query{Terms: []string{"Client!"}, Phrases: [][]string{{"New", "CLIENT"}}, Exclude: []string{"Soon"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hashes(results); len(got) != 1 || got[0] != "0x1" {
+		t.Errorf("Expected 0x1 for mixed case fields, got %v", got)
+	}
+
+	query, err := ParseQuery("gm -https://spam.com -fid:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query.Terms) != 1 || query.Terms[0] != "gm" || len(query.Exclude) != 5 || query.Fid != 0 {
+		t.Errorf("Expected excluded fields to stay out of the terms, got %+v", query)
+	}
+
+	// Terms repeated in a phrase aren't scored twice.
+	phrase, _ := index.Search(Query{Text: `"new client"`})
+	both, _ := index.Search(Query{Text: `new client "new client"`})
+	if len(phrase) != 1 || len(both) != 1 || phrase[0].Score != both[0].Score {
+		t.Errorf("Expected the same score with and without the phrase terms, got %v and %v", phrase, both)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testIndex().Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := index.Search(Query{Text: `"new client"`}); len(results) != 1 {
+		t.Errorf("Expected the phrase to match after loading, got %v", hashes(results))
+	}
+	index.Remove("0x1")
+	if index.Len() != 2 {
+		t.Errorf("Expected 2 casts after removal, got %d", index.Len())
+	}
+}
@@ -0,0 +1,109 @@
+package users
+
+import (
+	"strings"
+	"sync"
+)
+
+// UserResult is the outcome of one lookup of a batch.
+type UserResult struct {
+	User *User
+	Err  error
+}
+
+const defaultBatchConcurrency = 8
+
+// GetUsersByFids looks up every distinct fid with at most concurrency requests
+// in flight, 8 if it is zero. Every fid has an entry in the result.
+func (u *UserService) GetUsersByFids(fids []uint64, concurrency int) map[uint64]*UserResult {
+	results := make(map[uint64]*UserResult, len(fids))
+	var unique []uint64
+	for _, fid := range fids {
+		if _, ok := results[fid]; !ok {
+			results[fid] = &UserResult{}
+			unique = append(unique, fid)
+		}
+	}
+	forEach(len(unique), concurrency, func(i int) {
+		result := results[unique[i]]
+		result.User, result.Err = u.GetUserByFid(unique[i])
+	})
+	return results
+}
+
+// GetUsersByUsernames is GetUsersByFids for usernames. Results are keyed by
+// the lowercase username without a leading "@".
+func (u *UserService) GetUsersByUsernames(usernames []string, concurrency int) map[string]*UserResult {
+	results := make(map[string]*UserResult, len(usernames))
+	var unique []string
+	for _, username := range usernames {
+		username = strings.ToLower(strings.TrimPrefix(username, "@"))
+		if _, ok := results[username]; !ok {
+			results[username] = &UserResult{}
+			unique = append(unique, username)
+		}
+	}
+	forEach(len(unique), concurrency, func(i int) {
+		result := results[unique[i]]
+		result.User, result.Err = u.GetUserByUsername(unique[i])
+	})
+	return results
+}
+
+// forEach calls fn for 0..n-1 on at most concurrency goroutines.
+func forEach(n, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// flightGroup runs one request per key at a time and hands its result to
+// every caller that asked for the same key meanwhile.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	user *User
+	err  error
+}
+
+func (g *flightGroup) do(key string, fn func() (*User, error)) (*User, error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.user, f.err
+	}
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.user, f.err = fn()
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+	return f.user, f.err
+}
@@ -0,0 +1,59 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+)
+
+func TestGetUsersByFids(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// Keep requests in flight long enough to be coalesced.
+		time.Sleep(20 * time.Millisecond)
+		fid := r.URL.Query().Get("fid")
+		if fid == "4" {
+			fmt.Fprint(w, `{"errors":[{"message":"User not found"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"result":{"fid":%s,"username":"u%s"}}`, fid, fid)
+	}))
+	defer server.Close()
+	service := NewUserService(account.NewAccountService(server.URL, ""), nil)
+
+	var wg sync.WaitGroup
+	results := make([]map[uint64]*UserResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = service.GetUsersByFids([]uint64{1, 2, 2, 3, 4, 1}, 2)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if len(result) != 4 {
+			t.Fatalf("Expected 4 distinct fids, got %d", len(result))
+		}
+		if result[2].User == nil || result[2].User.Username != "u2" {
+			t.Errorf("Expected u2, got %+v", result[2])
+		}
+		if result[4].Err == nil || result[4].Err.Error() != "User not found" {
+			t.Errorf("Expected an error for fid 4, got %+v", result[4])
+		}
+	}
+	if results[0][1].User == results[1][1].User {
+		t.Error("Expected coalesced callers to get their own copy")
+	}
+	if n := atomic.LoadInt32(&requests); n >= 8 {
+		t.Errorf("Expected concurrent batches to share requests, got %d requests", n)
+	}
+}
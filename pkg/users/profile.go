@@ -0,0 +1,103 @@
+package users
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var bioLinkRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// RichProfile is a user with the mentions and links of its bio resolved.
+type RichProfile struct {
+	*User
+	// Mentions are the users mentioned in the bio, in order of appearance.
+	Mentions []User
+	// UnresolvedMentions are the usernames that didn't resolve to a user.
+	UnresolvedMentions []string
+	// Links are the URLs in the bio, with "https://" added to bare "www."
+	// links.
+	Links []string
+}
+
+// GetProfile fetches fid and resolves its bio.
+func (u *UserService) GetProfile(fid Fid) (*RichProfile, error) {
+	user, err := u.GetUserByFid(fid)
+	if err != nil {
+		return nil, err
+	}
+	return u.RichProfile(user), nil
+}
+
+// GetProfileByUsername fetches username and resolves its bio.
+func (u *UserService) GetProfileByUsername(username string) (*RichProfile, error) {
+	user, err := u.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	return u.RichProfile(user), nil
+}
+
+// RichProfile resolves the bio of a user that was already fetched.
+func (u *UserService) RichProfile(user *User) *RichProfile {
+	profile := &RichProfile{
+		User: user,
+	}
+	if user == nil {
+		return profile
+	}
+	profile.Links = ExtractLinks(user.Profile.Bio.Text)
+	usernames := BioMentions(&user.Profile.Bio)
+	results := u.GetUsersByUsernames(usernames, 0)
+	for _, username := range usernames {
+		result := results[username]
+		if result.Err != nil || result.User == nil {
+			profile.UnresolvedMentions = append(profile.UnresolvedMentions, username)
+			continue
+		}
+		profile.Mentions = append(profile.Mentions, *result.User)
+	}
+	return profile
+}
+
+// BioMentions returns the lowercase usernames mentioned in a bio, from the
+// API's Mentions if set or else parsed from the text, without duplicates.
+func BioMentions(bio *Bio) []string {
+	mentions := bio.Mentions
+	if len(mentions) == 0 {
+		for _, index := range MentionIndexes(bio.Text) {
+			mentions = append(mentions, bio.Text[index[0]:index[1]])
+		}
+	}
+	seen := make(map[string]bool, len(mentions))
+	var usernames []string
+	for _, mention := range mentions {
+		username := strings.ToLower(strings.TrimPrefix(mention, "@"))
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// ExtractLinks returns the http(s) and www. links in text, without trailing
+// punctuation and duplicates.
+func ExtractLinks(text string) []string {
+	seen := make(map[string]bool)
+	var links []string
+	for _, match := range bioLinkRegexp.FindAllString(text, -1) {
+		link := TrimLink(match)
+		if strings.HasPrefix(strings.ToLower(link), "www.") {
+			link = "https://" + link
+		}
+		parsed, err := url.Parse(link)
+		if err != nil || parsed.Host == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxUsernameLength is the maximum length of an fname.
+const MaxUsernameLength = 16
+
+// Mentions are matched with the characters of an fname so that a mention
+// ends where the username does, e.g. "@dwr_" mentions dwr. Upper case is
+// matched so ValidUsername can report it.
+var (
+	mentionRegexp  = regexp.MustCompile(`(^|[^\w@./])@([a-zA-Z0-9][a-zA-Z0-9-]*)`)
+	usernameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// ValidUsername reports whether username is a well formed fname.
+func ValidUsername(username string) bool {
+	return len(username) <= MaxUsernameLength && usernameRegexp.MatchString(username)
+}
+
+// MentionIndexes returns the byte ranges of the usernames mentioned in text,
+// without their '@'. Mentions aren't validated, see ValidUsername.
+func MentionIndexes(text string) [][]int {
+	var indexes [][]int
+	for _, match := range mentionRegexp.FindAllStringSubmatchIndex(text, -1) {
+		indexes = append(indexes, match[4:6])
+	}
+	return indexes
+}
+
+// TrimLink removes the punctuation that ends a sentence or closes brackets
+// from a link found in text.
+func TrimLink(link string) string {
+	return strings.TrimRight(link, ".,;:!?)]}'")
+}
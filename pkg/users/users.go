@@ -3,18 +3,26 @@ package users
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
 	"github.com/ertan/go-farcaster/pkg/registry"
 )
 
 type UserService struct {
 	account  *account.AccountService
 	registry *registry.RegistryService
+	flights  flightGroup
+	cache    *cache.Cache
 }
 
+// Fid identifies an account. It is an alias, so any uint64 is a Fid.
+type Fid = uint64
+
 type User struct {
-	Fid              int            `json:"fid"`
+	Fid              Fid            `json:"fid"`
 	Username         string         `json:"username"`
 	DisplayName      string         `json:"displayName"`
 	Pfp              Pfp            `json:"pfp"`
@@ -59,7 +67,20 @@ type UserResponse struct {
 	} `json:"errors"`
 }
 
+// getUser shares the response between concurrent identical requests.
 func (s *UserService) getUser(path string, params map[string]interface{}) (*User, error) {
+	// fmt prints maps with sorted keys, so the key is stable.
+	user, err := s.flights.do(path+fmt.Sprint(params), func() (*User, error) {
+		return s.fetchUser(path, params)
+	})
+	if user == nil {
+		return nil, err
+	}
+	copied := *user
+	return &copied, err
+}
+
+func (s *UserService) fetchUser(path string, params map[string]interface{}) (*User, error) {
 	responseBytes, err := s.account.SendRequest("GET", path, params, nil)
 	if err != nil {
 		return nil, err
@@ -75,12 +96,43 @@ func (s *UserService) getUser(path string, params map[string]interface{}) (*User
 	return nil, err
 }
 
+// SetCache caches users by fid and username and custody addresses by fid.
+func (u *UserService) SetCache(c *cache.Cache) {
+	u.cache = c
+}
+
 func (u *UserService) GetUserByFid(fid uint64) (*User, error) {
-	return u.getUser("/v2/user", map[string]interface{}{"fid": fid})
+	var cached User
+	if u.cache.Get(cache.KindUser, cache.Key(fid), &cached) {
+		return &cached, nil
+	}
+	return u.RefreshUserByFid(fid)
+}
+
+// RefreshUserByFid fetches fid without reading the cache, e.g. for a viewer
+// context that must be current, and caches the result.
+func (u *UserService) RefreshUserByFid(fid uint64) (*User, error) {
+	user, err := u.getUser("/v2/user", map[string]interface{}{"fid": fid})
+	if err == nil && user != nil {
+		u.cache.Set(cache.KindUser, cache.Key(fid), user)
+	}
+	return user, err
 }
 
 func (u *UserService) GetUserByUsername(username string) (*User, error) {
-	return u.getUser("/v2/user-by-username", map[string]interface{}{"username": username})
+	var fid uint64
+	if u.cache.Get(cache.KindUsername, strings.ToLower(username), &fid) {
+		var cached User
+		if u.cache.Get(cache.KindUser, cache.Key(fid), &cached) {
+			return &cached, nil
+		}
+	}
+	user, err := u.getUser("/v2/user-by-username", map[string]interface{}{"username": username})
+	if err == nil && user != nil {
+		u.cache.Set(cache.KindUsername, strings.ToLower(username), user.Fid)
+		u.cache.Set(cache.KindUser, cache.Key(user.Fid), user)
+	}
+	return user, err
 }
 
 func (u *UserService) GetUserByAddress(address string) (*User, error) {
@@ -118,7 +170,15 @@ func (u *UserService) getCustodyAddress(params map[string]interface{}) (string,
 }
 
 func (u *UserService) GetCustodyAddressByFid(fid uint64) (string, error) {
-	return u.getCustodyAddress(map[string]interface{}{"fid": fid})
+	var cached string
+	if u.cache.Get(cache.KindCustody, cache.Key(fid), &cached) {
+		return cached, nil
+	}
+	address, err := u.getCustodyAddress(map[string]interface{}{"fid": fid})
+	if err == nil && address != "" {
+		u.cache.Set(cache.KindCustody, cache.Key(fid), address)
+	}
+	return address, err
 }
 
 func (u *UserService) GetCustodyAddressByUsername(username string) (string, error) {
@@ -0,0 +1,107 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/account"
+	"github.com/ertan/go-farcaster/pkg/cache"
+)
+
+func TestGetUserByUsernameUsesCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"result":{"fid":3,"username":"dwr"}}`)
+	}))
+	defer server.Close()
+	service := NewUserService(account.NewAccountService(server.URL, ""), nil)
+	c := cache.New(nil)
+	service.SetCache(c)
+
+	for _, username := range []string{"dwr", "DWR"} {
+		if user, err := service.GetUserByUsername(username); err != nil || user.Fid != 3 {
+			t.Fatalf("Expected fid 3, got %+v, %v", user, err)
+		}
+	}
+	if user, err := service.GetUserByFid(3); err != nil || user.Username != "dwr" {
+		t.Fatalf("Expected dwr, got %+v, %v", user, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+	c.Delete(cache.KindUser, cache.Key(3))
+	service.GetUserByUsername("dwr")
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected invalidating the fid to refetch, got %d requests", n)
+	}
+	if stats := c.Stats()[cache.KindUser]; stats.Hits != 2 {
+		t.Errorf("Expected 2 user hits, got %+v", stats)
+	}
+}
+
+func TestWatchProfiles(t *testing.T) {
+	var bio atomic.Value
+	bio.Store("gm")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"result":{"fid":%s,"username":"u","followerCount":%d,"profile":{"bio":{"text":%q}}}}`,
+			r.URL.Query().Get("fid"), time.Now().UnixNano(), bio.Load())
+	}))
+	defer server.Close()
+	service := NewUserService(account.NewAccountService(server.URL, ""), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := service.WatchProfiles(ctx, []uint64{1, 2}, 10*time.Millisecond, &ProfileWatchOptions{
+		Fields: []Field{FieldDisplayName, FieldBio},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	bio.Store("gn")
+	seen := map[uint64]bool{}
+	for len(seen) < 2 {
+		select {
+		case event := <-events:
+			if len(event.Changes) != 1 || event.Changes[0] != (Change{FieldBio, "gm", "gn"}) {
+				t.Fatalf("Expected only the bio change, got %+v", event.Changes)
+			}
+			seen[event.Fid] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out, saw %v", seen)
+		}
+	}
+}
+
+func TestRichProfile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("username") {
+		case "v":
+			fmt.Fprint(w, `{"result":{"fid":2,"username":"v"}}`)
+		default:
+			fmt.Fprint(w, `{"errors":[{"message":"No FID associated with username"}]}`)
+		}
+	}))
+	defer server.Close()
+	service := NewUserService(account.NewAccountService(server.URL, ""), nil)
+
+	profile := service.RichProfile(&User{Fid: 1, Profile: Profile{Bio: Bio{
+		Text: "Building with @v and @nobody, email me@example.com. See www.example.com, https://x.org/a).",
+	}}})
+	if len(profile.Mentions) != 1 || profile.Mentions[0].Fid != 2 {
+		t.Errorf("Expected @v to resolve to fid 2, got %+v", profile.Mentions)
+	}
+	if len(profile.UnresolvedMentions) != 1 || profile.UnresolvedMentions[0] != "nobody" {
+		t.Errorf("Expected nobody to be unresolved, got %v", profile.UnresolvedMentions)
+	}
+	want := []string{"https://www.example.com", "https://x.org/a"}
+	if len(profile.Links) != 2 || profile.Links[0] != want[0] || profile.Links[1] != want[1] {
+		t.Errorf("Expected links %v, got %v", want, profile.Links)
+	}
+}
@@ -0,0 +1,156 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ertan/go-farcaster/pkg/cache"
+)
+
+type Field string
+
+const (
+	FieldUsername       Field = "username"
+	FieldDisplayName    Field = "displayName"
+	FieldPfpUrl         Field = "pfp.url"
+	FieldPfpVerified    Field = "pfp.verified"
+	FieldBio            Field = "profile.bio"
+	FieldFollowerCount  Field = "followerCount"
+	FieldFollowingCount Field = "followingCount"
+)
+
+// Change is a field whose value differs between two fetches of a user.
+// Values are formatted as strings, counts and flags included.
+type Change struct {
+	Field Field  `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type ProfileEvent struct {
+	Fid     uint64    `json:"fid"`
+	Time    time.Time `json:"time"`
+	Changes []Change  `json:"changes"`
+	Old     *User     `json:"old"`
+	New     *User     `json:"new"`
+}
+
+// DiffUsers lists the profile fields that differ from old to new.
+func DiffUsers(old, new *User) []Change {
+	var changes []Change
+	add := func(field Field, o, n string) {
+		if o != n {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+	add(FieldUsername, old.Username, new.Username)
+	add(FieldDisplayName, old.DisplayName, new.DisplayName)
+	add(FieldPfpUrl, old.Pfp.Url, new.Pfp.Url)
+	add(FieldPfpVerified, strconv.FormatBool(old.Pfp.Verified), strconv.FormatBool(new.Pfp.Verified))
+	add(FieldBio, old.Profile.Bio.Text, new.Profile.Bio.Text)
+	add(FieldFollowerCount, strconv.Itoa(old.FollowerCount), strconv.Itoa(new.FollowerCount))
+	add(FieldFollowingCount, strconv.Itoa(old.FollowingCount), strconv.Itoa(new.FollowingCount))
+	return changes
+}
+
+type ProfileWatchOptions struct {
+	// Fields limits the changes reported. Defaults to every field; counts
+	// change often, leave them out to only hear about profile edits.
+	Fields []Field
+	// Previous seeds the snapshots, e.g. from the last run, so changes made
+	// in between are reported on the first poll.
+	Previous map[uint64]*User
+	// Concurrency of the fetches of a poll. Defaults to 8.
+	Concurrency int
+	OnError     func(fid uint64, err error)
+}
+
+// WatchProfiles fetches fids every interval and emits an event for every user
+// whose watched fields changed since the previous fetch. Users are always
+// fetched from the API and the cache, if any, is refreshed with them. The
+// channel is closed when ctx is done.
+func (u *UserService) WatchProfiles(ctx context.Context, fids []uint64, interval time.Duration, options *ProfileWatchOptions) (<-chan ProfileEvent, error) {
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	if len(fids) == 0 {
+		return nil, errors.New("no fids to watch")
+	}
+	var opts ProfileWatchOptions
+	if options != nil {
+		opts = *options
+	}
+	previous := make(map[uint64]*User, len(fids))
+	for fid, user := range opts.Previous {
+		previous[fid] = user
+	}
+	fields := make(map[Field]bool, len(opts.Fields))
+	for _, field := range opts.Fields {
+		fields[field] = true
+	}
+	out := make(chan ProfileEvent)
+	go func() {
+		defer close(out)
+		wait := time.Duration(0)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			wait = interval
+			for _, event := range u.pollProfiles(fids, previous, fields, &opts) {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (u *UserService) pollProfiles(fids []uint64, previous map[uint64]*User, fields map[Field]bool, opts *ProfileWatchOptions) []ProfileEvent {
+	current := make([]*User, len(fids))
+	forEach(len(fids), opts.Concurrency, func(i int) {
+		user, err := u.getUser("/v2/user", map[string]interface{}{"fid": fids[i]})
+		if err == nil && user == nil {
+			err = fmt.Errorf("user %d not found", fids[i])
+		}
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(fids[i], err)
+			}
+			return
+		}
+		u.cache.Set(cache.KindUser, cache.Key(fids[i]), user)
+		current[i] = user
+	})
+	var events []ProfileEvent
+	now := time.Now()
+	for i, fid := range fids {
+		user := current[i]
+		if user == nil {
+			continue
+		}
+		old, ok := previous[fid]
+		previous[fid] = user
+		if !ok {
+			continue
+		}
+		var changes []Change
+		for _, change := range DiffUsers(old, user) {
+			if len(fields) == 0 || fields[change.Field] {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) > 0 {
+			events = append(events, ProfileEvent{Fid: fid, Time: now, Changes: changes, Old: old, New: user})
+		}
+	}
+	return events
+}